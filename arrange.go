@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type direction int
+
+const (
+	left direction = iota
+	right
+	up
+	down
+)
+
+var directionNames = []string{"left", "right", "up", "down"}
+
+func (d direction) String() string {
+	if 0 <= int(d) && int(d) < len(directionNames) {
+		return directionNames[d]
+	}
+	return fmt.Sprintf("direction(%d)", int(d))
+}
+
+func parseDirection(s string) (direction, error) {
+	for i, name := range directionNames {
+		if strings.ToLower(s) == name {
+			return direction(i), nil
+		}
+	}
+	return 0, fmt.Errorf(`invalid direction %q, use "left", "right", "up" or "down"`, s)
+}
+
+// occupiedTiles returns the tiles that a window with the given rect covers,
+// in tile units. It is the inverse of tilesToPixels: each edge goes to the
+// closest tile edge, so windows with gaps or slightly off positions still map
+// to their tiles. The result is at least one tile.
+func occupiedTiles(r rect, width, height, cols, rows int) rect {
+	tiles := rect{
+		left:   nearestTileEdge(r.left, width, cols),
+		top:    nearestTileEdge(r.top, height, rows),
+		right:  nearestTileEdge(r.right, width, cols),
+		bottom: nearestTileEdge(r.bottom, height, rows),
+	}
+	if tiles.right <= tiles.left {
+		tiles.left = min(tiles.left, cols-1)
+		tiles.right = tiles.left + 1
+	}
+	if tiles.bottom <= tiles.top {
+		tiles.top = min(tiles.top, rows-1)
+		tiles.bottom = tiles.top + 1
+	}
+	return tiles
+}
+
+// nearestTileEdge returns the index of the tile edge closest to pos, from 0 to
+// count.
+func nearestTileEdge(pos, size, count int) int {
+	best, bestDist := 0, -1
+	for i := 0; i <= count; i++ {
+		d := pos - tileEdge(i, size, count)
+		if d < 0 {
+			d = -d
+		}
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// neighbourTiles returns the strip of tiles right next to the given tiles in
+// the direction. It returns false at the edge of the grid.
+func neighbourTiles(tiles rect, d direction, cols, rows int) (rect, bool) {
+	n := tiles
+	switch d {
+	case left:
+		n.left, n.right = tiles.left-1, tiles.left
+	case right:
+		n.left, n.right = tiles.right, tiles.right+1
+	case up:
+		n.top, n.bottom = tiles.top-1, tiles.top
+	case down:
+		n.top, n.bottom = tiles.bottom, tiles.bottom+1
+	}
+	ok := n.left >= 0 && n.top >= 0 && n.right <= cols && n.bottom <= rows
+	return n, ok
+}
+
+// moveTiles moves the tiles one tile in the direction. At the edge of the
+// grid they cannot move any further, instead they shrink towards the edge,
+// down to a single tile.
+func moveTiles(tiles rect, d direction, cols, rows int) rect {
+	if _, ok := neighbourTiles(tiles, d, cols, rows); ok {
+		switch d {
+		case left:
+			return tiles.offset(-1, 0)
+		case right:
+			return tiles.offset(1, 0)
+		case up:
+			return tiles.offset(0, -1)
+		case down:
+			return tiles.offset(0, 1)
+		}
+	}
+	switch {
+	case d == left && tiles.width() > 1:
+		tiles.right--
+	case d == right && tiles.width() > 1:
+		tiles.left++
+	case d == up && tiles.height() > 1:
+		tiles.bottom--
+	case d == down && tiles.height() > 1:
+		tiles.top++
+	}
+	return tiles
+}
+
+// growTiles adds the neighbouring tiles in the direction. At the edge of the
+// grid the tiles shrink from the other side instead, so pressing the same key
+// repeatedly cycles through all sizes along that edge.
+func growTiles(tiles rect, d direction, cols, rows int) rect {
+	if n, ok := neighbourTiles(tiles, d, cols, rows); ok {
+		return rect{
+			left:   min(tiles.left, n.left),
+			top:    min(tiles.top, n.top),
+			right:  max(tiles.right, n.right),
+			bottom: max(tiles.bottom, n.bottom),
+		}
+	}
+	return moveTiles(tiles, d, cols, rows)
+}
+
+// windowInDirection returns the index of the window whose tiles are next to
+// the given tiles in the direction. If there are several, the one sharing the
+// longest border wins, then the first one. others are usually in z-order so
+// the top-most window wins ties.
+func windowInDirection(tiles rect, others []rect, d direction, cols, rows int) (int, bool) {
+	strip, ok := neighbourTiles(tiles, d, cols, rows)
+	if !ok {
+		return 0, false
+	}
+	best, bestOverlap := 0, 0
+	for i, o := range others {
+		intersects := o.left < strip.right && strip.left < o.right &&
+			o.top < strip.bottom && strip.top < o.bottom
+		if !intersects {
+			continue
+		}
+		// The shared border runs across the direction.
+		overlap := min(strip.right, o.right) - max(strip.left, o.left)
+		if d == left || d == right {
+			overlap = min(strip.bottom, o.bottom) - max(strip.top, o.top)
+		}
+		if overlap > bestOverlap {
+			best, bestOverlap = i, overlap
+		}
+	}
+	return best, bestOverlap > 0
+}
+
+// tileAction is what a tile command does to the active window.
+type tileAction int
+
+const (
+	moveAction tileAction = iota
+	growAction
+	swapAction
+	// sendAction moves the active window to another monitor.
+	sendAction
+)
+
+var tileActionNames = []string{"move", "grow", "swap", "send"}
+
+func (a tileAction) String() string {
+	if 0 <= int(a) && int(a) < len(tileActionNames) {
+		return tileActionNames[a]
+	}
+	return fmt.Sprintf("tileAction(%d)", int(a))
+}
+
+// tileCommand is a command like "move left", "swap up" or "send next", as
+// used on the command line and for hotkeys.
+type tileCommand struct {
+	action tileAction
+	dir    direction
+	// step is 1 to send the window to the next monitor and -1 for the
+	// previous one.
+	step int
+}
+
+func (c tileCommand) String() string {
+	if c.action == sendAction {
+		if c.step < 0 {
+			return "send previous"
+		}
+		return "send next"
+	}
+	return c.action.String() + " " + c.dir.String()
+}
+
+func parseTileCommand(s string) (tileCommand, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return tileCommand{}, fmt.Errorf(
+			`invalid command %q, use an action and a direction like "move left"`,
+			s,
+		)
+	}
+	var c tileCommand
+	found := false
+	for i, name := range tileActionNames {
+		if strings.ToLower(fields[0]) == name {
+			c.action = tileAction(i)
+			found = true
+		}
+	}
+	if !found {
+		return tileCommand{}, fmt.Errorf(
+			`invalid action %q, use "move", "grow", "swap" or "send"`,
+			fields[0],
+		)
+	}
+	if c.action == sendAction {
+		switch strings.ToLower(fields[1]) {
+		case "next":
+			c.step = 1
+		case "previous":
+			c.step = -1
+		default:
+			return tileCommand{}, fmt.Errorf(
+				`invalid monitor %q, use "next" or "previous"`,
+				fields[1],
+			)
+		}
+		return c, nil
+	}
+	d, err := parseDirection(fields[1])
+	if err != nil {
+		return tileCommand{}, err
+	}
+	c.dir = d
+	return c, nil
+}
+
+// hotkey is a key combination like "ctrl+alt+left".
+type hotkey struct {
+	mods modifiers
+	// key is the lower case name of the key: "left", "right", "up", "down",
+	// "pageup", "pagedown", a letter, a digit or "f1" to "f12".
+	key string
+}
+
+func parseHotkey(s string) (hotkey, error) {
+	var h hotkey
+	parts := strings.Split(strings.ToLower(s), "+")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	for _, p := range parts[:len(parts)-1] {
+		if p == "win" {
+			h.mods |= winKey
+			continue
+		}
+		m, err := parseModifier(p)
+		if err != nil {
+			return hotkey{}, fmt.Errorf("hotkey %q: %w", s, err)
+		}
+		h.mods |= m
+	}
+	h.key = parts[len(parts)-1]
+	if !validHotkeyKey(h.key) {
+		return hotkey{}, fmt.Errorf(
+			"hotkey %q: invalid key %q, use an arrow key, page up or down, a letter, a digit or F1 to F12",
+			s, h.key,
+		)
+	}
+	if h.mods == 0 {
+		return hotkey{}, fmt.Errorf("hotkey %q needs at least one modifier", s)
+	}
+	return h, nil
+}
+
+func validHotkeyKey(key string) bool {
+	switch key {
+	case "left", "right", "up", "down", "pageup", "pagedown":
+		return true
+	}
+	if len(key) == 1 && ('a' <= key[0] && key[0] <= 'z' || '0' <= key[0] && key[0] <= '9') {
+		return true
+	}
+	for i := 1; i <= 12; i++ {
+		if key == fmt.Sprintf("f%d", i) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultHotkeys move windows with Ctrl+Alt+arrow, swap them with
+// Ctrl+Alt+Shift+arrow and send them to the next or previous monitor with
+// Ctrl+Alt+PageDown and Ctrl+Alt+PageUp.
+func defaultHotkeys() map[string]string {
+	keys := map[string]string{
+		"ctrl+alt+pagedown": "send next",
+		"ctrl+alt+pageup":   "send previous",
+	}
+	for _, d := range directionNames {
+		keys["ctrl+alt+"+d] = "move " + d
+		keys["ctrl+alt+shift+"+d] = "swap " + d
+	}
+	return keys
+}
@@ -0,0 +1,250 @@
+package main
+
+import "testing"
+
+func TestOccupiedTilesRoundTrip(t *testing.T) {
+	// The work area of a 1920x1080 monitor with the taskbar at the bottom.
+	const width, height = 1920, 1040
+	// Windows 10 and later give windows an invisible border for resizing,
+	// it is part of their window rect on the left, right and bottom.
+	const border = 7
+	tests := []struct {
+		name  string
+		place func(r rect) rect
+	}{
+		{name: "exact", place: func(r rect) rect { return r }},
+		{
+			name: "with gaps",
+			place: func(r rect) rect {
+				return withGaps(r, defaultConfig().Gap, width, height)
+			},
+		},
+		{
+			name: "with invisible borders",
+			place: func(r rect) rect {
+				r.left -= border
+				r.right += border
+				r.bottom += border
+				return r
+			},
+		},
+		{
+			name: "with gaps and invisible borders",
+			place: func(r rect) rect {
+				r = withGaps(r, 16, width, height)
+				r.left -= border
+				r.right += border
+				r.bottom += border
+				return r
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for cols := 1; cols <= 5; cols++ {
+				for rows := 1; rows <= 4; rows++ {
+					for _, tiles := range allTileRanges(cols, rows) {
+						r := tt.place(tilesToPixels(tiles, width, height, cols, rows))
+						got := occupiedTiles(r, width, height, cols, rows)
+						if got != tiles {
+							t.Errorf("%dx%d: tiles %v placed at %v give %v", cols, rows, tiles, r, got)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// allTileRanges returns every rectangle of tiles in the grid.
+func allTileRanges(cols, rows int) []rect {
+	var all []rect
+	for left := 0; left < cols; left++ {
+		for right := left + 1; right <= cols; right++ {
+			for top := 0; top < rows; top++ {
+				for bottom := top + 1; bottom <= rows; bottom++ {
+					all = append(all, rect{left, top, right, bottom})
+				}
+			}
+		}
+	}
+	return all
+}
+
+func TestOccupiedTilesOfOddWindows(t *testing.T) {
+	// 3x2 tiles of 400x300 pixels.
+	const width, height, cols, rows = 1200, 600, 3, 2
+	tests := []struct {
+		name string
+		r    rect
+		want rect
+	}{
+		{name: "slightly off", r: rect{390, 20, 820, 310}, want: rect{1, 0, 2, 1}},
+		{name: "smaller than a tile", r: rect{450, 350, 500, 400}, want: rect{1, 1, 2, 2}},
+		{name: "at the right edge", r: rect{1150, 0, 1200, 100}, want: rect{2, 0, 3, 1}},
+		{name: "larger than the work area", r: rect{-100, -100, 1300, 700}, want: rect{0, 0, 3, 2}},
+		{name: "off to the right", r: rect{1300, 0, 1500, 600}, want: rect{2, 0, 3, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occupiedTiles(tt.r, width, height, cols, rows)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveTiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiles rect
+		d     direction
+		want  rect
+	}{
+		{name: "right", tiles: rect{0, 0, 1, 1}, d: right, want: rect{1, 0, 2, 1}},
+		{name: "down", tiles: rect{1, 0, 3, 2}, d: down, want: rect{1, 1, 3, 3}},
+		{name: "left shrinks at the edge", tiles: rect{0, 0, 2, 1}, d: left, want: rect{0, 0, 1, 1}},
+		{name: "right shrinks at the edge", tiles: rect{1, 1, 3, 2}, d: right, want: rect{2, 1, 3, 2}},
+		{name: "up shrinks at the edge", tiles: rect{0, 0, 3, 3}, d: up, want: rect{0, 0, 3, 2}},
+		{name: "down shrinks at the edge", tiles: rect{0, 1, 1, 3}, d: down, want: rect{0, 2, 1, 3}},
+		{name: "a single tile stays at the edge", tiles: rect{0, 0, 1, 1}, d: left, want: rect{0, 0, 1, 1}},
+		{name: "a single row stays at the edge", tiles: rect{0, 2, 3, 3}, d: down, want: rect{0, 2, 3, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moveTiles(tt.tiles, tt.d, 3, 3); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrowTiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiles rect
+		d     direction
+		want  rect
+	}{
+		{name: "right", tiles: rect{0, 0, 1, 1}, d: right, want: rect{0, 0, 2, 1}},
+		{name: "up", tiles: rect{1, 1, 2, 2}, d: up, want: rect{1, 0, 2, 2}},
+		{name: "left", tiles: rect{1, 0, 3, 3}, d: left, want: rect{0, 0, 3, 3}},
+		{name: "down", tiles: rect{0, 0, 3, 1}, d: down, want: rect{0, 0, 3, 2}},
+		{name: "shrinks from the other side at the edge", tiles: rect{0, 0, 3, 1}, d: right, want: rect{1, 0, 3, 1}},
+		{name: "down at the edge", tiles: rect{0, 1, 1, 3}, d: down, want: rect{0, 2, 1, 3}},
+		{name: "a single tile stays at the edge", tiles: rect{2, 0, 3, 1}, d: right, want: rect{2, 0, 3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := growTiles(tt.tiles, tt.d, 3, 3); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrowTilesCyclesAlongTheEdge(t *testing.T) {
+	tiles := rect{0, 0, 1, 1}
+	var got []rect
+	for i := 0; i < 5; i++ {
+		tiles = growTiles(tiles, right, 3, 3)
+		got = append(got, tiles)
+	}
+	want := []rect{{0, 0, 2, 1}, {0, 0, 3, 1}, {1, 0, 3, 1}, {2, 0, 3, 1}, {2, 0, 3, 1}}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("press %d: got %v, want %v", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestWindowInDirection(t *testing.T) {
+	tests := []struct {
+		name   string
+		tiles  rect
+		others []rect
+		d      direction
+		want   int
+		wantOK bool
+	}{
+		{
+			name:   "the only neighbour",
+			tiles:  rect{0, 0, 1, 3},
+			others: []rect{{1, 0, 3, 3}},
+			d:      right,
+			want:   0, wantOK: true,
+		},
+		{
+			name:   "the longest shared border wins",
+			tiles:  rect{0, 0, 1, 3},
+			others: []rect{{1, 0, 2, 1}, {1, 1, 3, 3}},
+			d:      right,
+			want:   1, wantOK: true,
+		},
+		{
+			name:   "the first one wins ties",
+			tiles:  rect{1, 1, 2, 2},
+			others: []rect{{2, 1, 3, 3}, {2, 0, 3, 2}},
+			d:      right,
+			want:   0, wantOK: true,
+		},
+		{
+			name:   "up measures the border horizontally",
+			tiles:  rect{0, 1, 3, 2},
+			others: []rect{{0, 0, 1, 1}, {1, 0, 3, 1}},
+			d:      up,
+			want:   1, wantOK: true,
+		},
+		{
+			name:   "windows further away do not count",
+			tiles:  rect{0, 0, 1, 1},
+			others: []rect{{2, 0, 3, 1}, {0, 1, 1, 2}},
+			d:      right,
+		},
+		{
+			name:   "nothing at the edge",
+			tiles:  rect{2, 0, 3, 1},
+			others: []rect{{0, 0, 2, 1}},
+			d:      right,
+		},
+		{
+			name:  "no other windows",
+			tiles: rect{0, 0, 1, 1},
+			d:     down,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := windowInDirection(tt.tiles, tt.others, tt.d, 3, 3)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %d %v, want %d %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    hotkey
+		wantErr bool
+	}{
+		{s: "ctrl+alt+left", want: hotkey{mods: ctrlKey | altKey, key: "left"}},
+		{s: "Ctrl+Shift+PageDown", want: hotkey{mods: ctrlKey | shiftKey, key: "pagedown"}},
+		{s: "win+f12", want: hotkey{mods: winKey, key: "f12"}},
+		{s: "ctrl+ alt +left", want: hotkey{mods: ctrlKey | altKey, key: "left"}},
+		{s: " ctrl + 7 ", want: hotkey{mods: ctrlKey, key: "7"}},
+		{s: "left", wantErr: true},
+		{s: "ctrl+meta+left", wantErr: true},
+		{s: "ctrl+f13", wantErr: true},
+		{s: "ctrl+", wantErr: true},
+		{s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHotkey(tt.s)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%q: got %+v %v, want %+v, error: %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
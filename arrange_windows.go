@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gonutz/tile_screen/bsp"
+	"github.com/gonutz/w32"
+)
+
+// runTileCommand moves, grows or swaps the active window in the grid of its
+// monitor, or sends it to another monitor.
+func runTileCommand(c tileCommand, cfg config) error {
+	// Work in physical pixels, otherwise Windows scales the coordinates of
+	// monitors with another DPI than the main monitor.
+	defer usePhysicalPixels()()
+
+	window := w32.GetForegroundWindow()
+	if window == 0 {
+		return errors.New("there is no active window")
+	}
+	monitor := w32.MonitorFromWindow(window, w32.MONITOR_DEFAULTTONULL)
+	if monitor == 0 {
+		return errors.New("the active window is not on any monitor")
+	}
+	var info w32.MONITORINFO
+	if !w32.GetMonitorInfo(monitor, &info) {
+		return lastError("GetMonitorInfo")
+	}
+	if !isTileable(window, info, cfg) {
+		return errors.New("the active window cannot be tiled")
+	}
+	if c.action == sendAction {
+		return sendToMonitor(window, monitor, info, c.step, cfg)
+	}
+
+	cols, rows := gridOfMonitor(cfg, monitor)
+	tiles := windowTiles(window, info, cols, rows)
+
+	move := func(window w32.HWND, tiles rect) {
+		r, ok := tilesOnMonitor(tiles, info, cols, rows, windowSizeLimits(window), cfg)
+		if !ok {
+			log.infof("not moving window %q, it does not fit into tiles %v", w32.GetWindowText(window), tiles)
+			return
+		}
+		log.infof("%s: placing window %q at %v", c, w32.GetWindowText(window), r)
+		placeWindow(window, r, info)
+		notePlacement(window, monitor, tiles, cols, rows)
+	}
+
+	switch c.action {
+	case moveAction:
+		move(window, moveTiles(tiles, c.dir, cols, rows))
+	case growAction:
+		move(window, growTiles(tiles, c.dir, cols, rows))
+	case swapAction:
+		var others []w32.HWND
+		var otherTiles []rect
+		for _, w := range tileableWindows(info, cfg, window) {
+			if w32.MonitorFromWindow(w, w32.MONITOR_DEFAULTTONULL) != monitor ||
+				queryWindowState(w, info) == minimizedWindow {
+				continue
+			}
+			others = append(others, w)
+			otherTiles = append(otherTiles, windowTiles(w, info, cols, rows))
+		}
+		i, ok := windowInDirection(tiles, otherTiles, c.dir, cols, rows)
+		if !ok {
+			return fmt.Errorf("there is no window %s of the active window", c.dir)
+		}
+		move(window, otherTiles[i])
+		move(others[i], tiles)
+	}
+	return nil
+}
+
+// sendToMonitor moves the window to the next monitor for step 1 or the
+// previous one for step -1. It goes into the same relative tiles in the grid
+// of that monitor.
+func sendToMonitor(window w32.HWND, from w32.HMONITOR, fromInfo w32.MONITORINFO, step int, cfg config) error {
+	monitors := enumMonitors()
+	if len(monitors) < 2 {
+		return errors.New("there is no other monitor")
+	}
+	current := 0
+	bounds := make([]rect, len(monitors))
+	for i, m := range monitors {
+		var info w32.MONITORINFO
+		if w32.GetMonitorInfo(m, &info) {
+			bounds[i] = fromRECT(info.RcMonitor)
+		}
+		if m == from {
+			current = i
+		}
+	}
+	to := monitors[adjacentMonitor(bounds, current, step)]
+
+	fromCols, fromRows := gridOfMonitor(cfg, from)
+	toCols, toRows := gridOfMonitor(cfg, to)
+	tiles := rescaleTiles(
+		windowTiles(window, fromInfo, fromCols, fromRows),
+		fromCols, fromRows, toCols, toRows,
+	)
+	log.infof(
+		"sending window %q from %s (%dx%d grid, %d DPI) to %s (%dx%d grid, %d DPI)",
+		w32.GetWindowText(window),
+		identifyMonitor(from).id(), fromCols, fromRows, monitorDPI(from),
+		identifyMonitor(to).id(), toCols, toRows, monitorDPI(to),
+	)
+	_, err := placeOnMonitor(window, from, to, tiles, toCols, toRows, cfg)
+	return err
+}
+
+// placeOnMonitor puts the window into the tiles of the grid on monitor to,
+// coming from monitor from, which can be the same. It returns where the
+// window goes.
+func placeOnMonitor(window w32.HWND, from, to w32.HMONITOR, tiles rect, cols, rows int, cfg config) (rect, error) {
+	var toInfo w32.MONITORINFO
+	if !w32.GetMonitorInfo(to, &toInfo) {
+		return rect{}, lastError("GetMonitorInfo")
+	}
+	fromDPI, toDPI := monitorDPI(from), monitorDPI(to)
+	limits := scaleSizeLimits(windowSizeLimits(window), fromDPI, toDPI)
+	r, ok := tilesOnMonitor(tiles, toInfo, cols, rows, limits, cfg)
+	if !ok {
+		return rect{}, fmt.Errorf(
+			"window %q does not fit into tiles %v on %s",
+			w32.GetWindowText(window), tiles, identifyMonitor(to).id(),
+		)
+	}
+	log.infof("placing window %q at %v", w32.GetWindowText(window), r)
+	if fromDPI != toDPI {
+		// Windows that handle WM_DPICHANGED resize themselves when they reach
+		// the other monitor. Move the window there first so this does not
+		// undo the final size.
+		w32.SetWindowPos(
+			window, 0, r.left, r.top, 0, 0,
+			w32.SWP_ASYNCWINDOWPOS|w32.SWP_NOACTIVATE|w32.SWP_NOOWNERZORDER|w32.SWP_NOZORDER|w32.SWP_NOSIZE,
+		)
+	}
+	placeWindow(window, r, toInfo)
+	notePlacement(window, to, tiles, cols, rows)
+	return r, nil
+}
+
+// tilesOnMonitor returns the rect in screen coordinates for a window with the
+// given size limits in the tiles of the monitor. It returns false if the
+// window does not fit and the config says to refuse placing it.
+func tilesOnMonitor(tiles rect, info w32.MONITORINFO, cols, rows int, limits sizeLimits, cfg config) (rect, bool) {
+	strategy, _ := cfg.sizeStrategy()
+	p, ok := placeTiles(tiles, cfg.Gaps, dragOptions{
+		width:    int(info.RcWork.Width()),
+		height:   int(info.RcWork.Height()),
+		cols:     cols,
+		rows:     rows,
+		limits:   limits,
+		strategy: strategy,
+		gap:      cfg.Gap,
+	})
+	return p.rect.offset(int(info.RcWork.Left), int(info.RcWork.Top)), ok
+}
+
+// leafOnMonitor returns the rect in screen coordinates for a window with the
+// given size limits in the leaf of the split layout on the monitor. The leaf
+// is its index in tree.Leaves.
+func leafOnMonitor(tree *bsp.Node, leaf int, info w32.MONITORINFO, limits sizeLimits, cfg config) rect {
+	width, height := int(info.RcWork.Width()), int(info.RcWork.Height())
+	var r rect
+	if leaves := tree.Leaves(toBSP(rect{right: width, bottom: height})); leaf < len(leaves) {
+		r = fromBSP(leaves[leaf].Bounds)
+	}
+	r = fitPixels(r, width, height, limits)
+	if cfg.Gaps {
+		g := withGaps(r, cfg.Gap, width, height)
+		if g.width() >= limits.minWidth && g.height() >= limits.minHeight {
+			r = g
+		}
+	}
+	return r.offset(int(info.RcWork.Left), int(info.RcWork.Top))
+}
+
+// windowTiles returns the tiles that the window covers on the monitor.
+func windowTiles(window w32.HWND, info w32.MONITORINFO, cols, rows int) rect {
+	r := w32.GetWindowRect(window)
+	if r == nil {
+		return rect{right: 1, bottom: 1}
+	}
+	return occupiedTiles(
+		fromRECT(*r).offset(-int(info.RcWork.Left), -int(info.RcWork.Top)),
+		int(info.RcWork.Width()), int(info.RcWork.Height()),
+		cols, rows,
+	)
+}
+
+// virtualKey returns the virtual key code for a key name of a hotkey.
+func virtualKey(key string) int {
+	switch key {
+	case "left":
+		return w32.VK_LEFT
+	case "right":
+		return w32.VK_RIGHT
+	case "up":
+		return w32.VK_UP
+	case "down":
+		return w32.VK_DOWN
+	case "pageup":
+		return w32.VK_PRIOR
+	case "pagedown":
+		return w32.VK_NEXT
+	}
+	if len(key) == 1 {
+		// Letters and digits have their upper case ASCII code.
+		c := key[0]
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		return int(c)
+	}
+	var n int
+	fmt.Sscanf(key, "f%d", &n)
+	return w32.VK_F1 + n - 1
+}
+
+// hotkeyModifiers returns the MOD_ flags for RegisterHotKey.
+func hotkeyModifiers(m modifiers) uint {
+	flags := uint(modNoRepeat)
+	if m&altKey != 0 {
+		flags |= modAlt
+	}
+	if m&ctrlKey != 0 {
+		flags |= modControl
+	}
+	if m&shiftKey != 0 {
+		flags |= modShift
+	}
+	if m&winKey != 0 {
+		flags |= modWin
+	}
+	return flags
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// autoLayout is how auto-tiling arranges all windows on a monitor.
+type autoLayout int
+
+const (
+	// masterStack puts the first window on the left and stacks all others on
+	// top of each other on the right.
+	masterStack autoLayout = iota
+	// columnsLayout puts all windows side by side in columns of equal width.
+	columnsLayout
+	// bspLayout gives the first window half of the work area, the next one
+	// half of the rest and so on, splitting along the longer side.
+	bspLayout
+)
+
+var autoLayoutNames = []string{"master-stack", "columns", "bsp"}
+
+func (l autoLayout) String() string {
+	if 0 <= int(l) && int(l) < len(autoLayoutNames) {
+		return autoLayoutNames[l]
+	}
+	return fmt.Sprintf("autoLayout(%d)", int(l))
+}
+
+func parseAutoLayout(s string) (autoLayout, error) {
+	for i, name := range autoLayoutNames {
+		if strings.ToLower(s) == name {
+			return autoLayout(i), nil
+		}
+	}
+	return 0, fmt.Errorf(
+		`invalid auto-tiling layout %q, use "master-stack", "columns" or "bsp"`,
+		s,
+	)
+}
+
+// autoTile returns the rects of n windows in a work area of the given size,
+// in pixels relative to the work area. The rects cover the whole work area
+// without overlapping. ratio is the part of the width that the master window
+// gets in the masterStack layout.
+func autoTile(l autoLayout, n, width, height int, ratio float64) []rect {
+	if n <= 0 {
+		return nil
+	}
+	full := rect{right: width, bottom: height}
+	switch l {
+	case columnsLayout:
+		return splitEvenly(full, n, true)
+	case bspLayout:
+		rects := make([]rect, n)
+		rest := full
+		for i := range rects {
+			if i == n-1 {
+				rects[i] = rest
+			} else if rest.width() >= rest.height() {
+				x := rest.left + rest.width()/2
+				rects[i] = rect{left: rest.left, top: rest.top, right: x, bottom: rest.bottom}
+				rest.left = x
+			} else {
+				y := rest.top + rest.height()/2
+				rects[i] = rect{left: rest.left, top: rest.top, right: rest.right, bottom: y}
+				rest.top = y
+			}
+		}
+		return rects
+	}
+	if n == 1 {
+		return []rect{full}
+	}
+	x := int(float64(width)*ratio + 0.5)
+	master := rect{right: x, bottom: height}
+	stack := rect{left: x, right: width, bottom: height}
+	return append([]rect{master}, splitEvenly(stack, n-1, false)...)
+}
+
+// splitEvenly splits r into n parts side by side, or on top of each other if
+// horizontal is false. Their sizes differ by at most one pixel.
+func splitEvenly(r rect, n int, horizontal bool) []rect {
+	parts := make([]rect, n)
+	for i := range parts {
+		p := r
+		if horizontal {
+			p.left = r.left + i*r.width()/n
+			p.right = r.left + (i+1)*r.width()/n
+		} else {
+			p.top = r.top + i*r.height()/n
+			p.bottom = r.top + (i+1)*r.height()/n
+		}
+		parts[i] = p
+	}
+	return parts
+}
+
+// mergeWindowOrder returns the windows in current, ordered like in order.
+// Windows that are not in order yet go to the end, so newly opened windows
+// join the stack instead of replacing the master window.
+func mergeWindowOrder(order, current []uintptr) []uintptr {
+	present := map[uintptr]bool{}
+	for _, w := range current {
+		present[w] = true
+	}
+	var merged []uintptr
+	known := map[uintptr]bool{}
+	for _, w := range order {
+		if present[w] {
+			merged = append(merged, w)
+			known[w] = true
+		}
+	}
+	for _, w := range current {
+		if !known[w] {
+			merged = append(merged, w)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutoTile(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout autoLayout
+		n      int
+		want   []rect
+	}{
+		{name: "no windows", layout: masterStack, n: 0},
+		{
+			name:   "master-stack with one window",
+			layout: masterStack,
+			n:      1,
+			want:   []rect{{0, 0, 1000, 600}},
+		},
+		{
+			name:   "master-stack",
+			layout: masterStack,
+			n:      3,
+			want:   []rect{{0, 0, 600, 600}, {600, 0, 1000, 300}, {600, 300, 1000, 600}},
+		},
+		{
+			name:   "columns",
+			layout: columnsLayout,
+			n:      3,
+			want:   []rect{{0, 0, 333, 600}, {333, 0, 666, 600}, {666, 0, 1000, 600}},
+		},
+		{
+			name:   "bsp",
+			layout: bspLayout,
+			n:      4,
+			want: []rect{
+				{0, 0, 500, 600},
+				{500, 0, 1000, 300},
+				{500, 300, 750, 600},
+				{750, 300, 1000, 600},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := autoTile(tt.layout, tt.n, 1000, 600, 0.6)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAutoTileCoversWorkArea checks that every layout covers the whole work
+// area without overlapping windows.
+func TestAutoTileCoversWorkArea(t *testing.T) {
+	const width, height = 1366, 745
+	for l := range autoLayoutNames {
+		for n := 1; n <= 8; n++ {
+			rects := autoTile(autoLayout(l), n, width, height, 0.55)
+			if len(rects) != n {
+				t.Fatalf("%v with %d windows: got %d rects", autoLayout(l), n, len(rects))
+			}
+			area := 0
+			for i, a := range rects {
+				if a.left < 0 || a.top < 0 || a.right > width || a.bottom > height || a.width() <= 0 || a.height() <= 0 {
+					t.Errorf("%v with %d windows: rect %v is outside of the work area", autoLayout(l), n, a)
+				}
+				area += a.width() * a.height()
+				for _, b := range rects[i+1:] {
+					if a.left < b.right && b.left < a.right && a.top < b.bottom && b.top < a.bottom {
+						t.Errorf("%v with %d windows: %v and %v overlap", autoLayout(l), n, a, b)
+					}
+				}
+			}
+			if area != width*height {
+				t.Errorf("%v with %d windows: the rects cover %d pixels, want %d", autoLayout(l), n, area, width*height)
+			}
+		}
+	}
+}
+
+func TestMergeWindowOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		order, current []uintptr
+		want           []uintptr
+	}{
+		{name: "no windows"},
+		{name: "first reflow", current: []uintptr{3, 1, 2}, want: []uintptr{3, 1, 2}},
+		{name: "same windows keep their order", order: []uintptr{1, 2, 3}, current: []uintptr{3, 2, 1}, want: []uintptr{1, 2, 3}},
+		{name: "new windows go to the end", order: []uintptr{1, 2}, current: []uintptr{4, 2, 1}, want: []uintptr{1, 2, 4}},
+		{name: "closed windows are dropped", order: []uintptr{1, 2, 3}, current: []uintptr{3, 1}, want: []uintptr{1, 3}},
+		{name: "closed master", order: []uintptr{1, 2, 3}, current: []uintptr{5, 3, 2}, want: []uintptr{2, 3, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeWindowOrder(tt.order, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"syscall"
+
+	"github.com/gonutz/w32"
+)
+
+// autoTiler keeps all normal windows tiled in resident mode. Opening, closing,
+// minimizing and moving windows re-flows the windows on all monitors.
+type autoTiler struct {
+	cfg    config
+	layout autoLayout
+	// order is the order of the windows in the layout, the first window on
+	// each monitor is the master window.
+	order []uintptr
+	// manual are the windows that the user put into tiles by hand, e.g. by
+	// snapping or with a hotkey. They stay where the user put them and are
+	// left out of the layout until they are moved without snapping.
+	manual map[uintptr]bool
+	// timer is set while a re-flow is pending. Windows send events in bursts,
+	// they are collected for a moment before re-flowing only once.
+	timer uintptr
+}
+
+var reflowCallback = syscall.NewCallback(func(window w32.HWND, msg uint32, id, time uintptr) uintptr {
+	if a := theResident.auto; a != nil {
+		killTimer.Call(0, a.timer)
+		a.timer = 0
+		a.reflow()
+	}
+	return 0
+})
+
+// autoTileEvents are the WinEvents that can change which windows are tiled,
+// as ranges of event IDs.
+var autoTileEvents = [][2]uint32{
+	{eventSystemMinimizeStart, eventSystemMinimizeEnd},
+	{eventObjectDestroy, eventObjectHide},
+}
+
+func newAutoTiler(cfg config) *autoTiler {
+	layout, _ := parseAutoLayout(cfg.AutoTile)
+	return &autoTiler{cfg: cfg, layout: layout, manual: map[uintptr]bool{}}
+}
+
+// scheduleReflow re-flows the windows a little later.
+func (a *autoTiler) scheduleReflow() {
+	if a.timer == 0 {
+		const delay = 100 // ms
+		a.timer = w32.SetTimer(0, 0, delay, reflowCallback)
+	}
+}
+
+// reflow tiles all normal windows on every monitor, except those placed by
+// hand.
+func (a *autoTiler) reflow() {
+	for w := range a.manual {
+		if !w32.IsWindow(w32.HWND(w)) {
+			delete(a.manual, w)
+		}
+	}
+	var current []uintptr
+	monitors := map[uintptr]w32.HMONITOR{}
+	infos := map[w32.HMONITOR]w32.MONITORINFO{}
+	for _, window := range enumWindows() {
+		monitor := w32.MonitorFromWindow(window, w32.MONITOR_DEFAULTTONULL)
+		if monitor == 0 {
+			continue
+		}
+		info, ok := infos[monitor]
+		if !ok {
+			if !w32.GetMonitorInfo(monitor, &info) {
+				continue
+			}
+			infos[monitor] = info
+		}
+		if a.manual[uintptr(window)] || !isTileable(window, info, a.cfg) {
+			continue
+		}
+		switch queryWindowState(window, info) {
+		case normalWindow, snappedWindow:
+			current = append(current, uintptr(window))
+			monitors[uintptr(window)] = monitor
+		}
+	}
+	a.order = mergeWindowOrder(a.order, current)
+
+	for monitor, info := range infos {
+		var windows []uintptr
+		for _, w := range a.order {
+			if monitors[w] == monitor {
+				windows = append(windows, w)
+			}
+		}
+		width, height := int(info.RcWork.Width()), int(info.RcWork.Height())
+		rects := autoTile(a.layout, len(windows), width, height, a.cfg.MasterRatio)
+		for i, w := range windows {
+			r := rects[i]
+			if a.cfg.Gaps {
+				r = withGaps(r, a.cfg.Gap, width, height)
+			}
+			r = r.offset(int(info.RcWork.Left), int(info.RcWork.Top))
+			if current := w32.GetWindowRect(w32.HWND(w)); current != nil && fromRECT(*current) == r {
+				continue
+			}
+			log.debugf("auto-tiling window %q at %v", w32.GetWindowText(w32.HWND(w)), r)
+			placeWindow(w32.HWND(w), r, info)
+		}
+	}
+}
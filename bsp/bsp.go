@@ -0,0 +1,231 @@
+// Package bsp models layouts that split an area recursively in two, a binary
+// space partition. Each node is either a leaf, a region that a window goes
+// into, or it is split into two children side by side or on top of each
+// other. Nodes do not know their size, it is computed from the bounds of the
+// whole area so the same tree works for any monitor.
+package bsp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Orientation is how a node is split into its two children.
+type Orientation string
+
+const (
+	// LeftRight puts the first child on the left and the second one on the
+	// right.
+	LeftRight Orientation = "left-right"
+	// TopBottom puts the first child on top of the second one.
+	TopBottom Orientation = "top-bottom"
+)
+
+// MinRatio is the smallest ratio of a split, the largest is 1-MinRatio. This
+// keeps both children visible.
+const MinRatio = 0.05
+
+// Node is a region of the layout. Its JSON form is what layouts store in the
+// config file.
+type Node struct {
+	// Split is empty for leaves.
+	Split Orientation `json:"split,omitempty"`
+	// Ratio is the part of the node that the first child gets.
+	Ratio  float64 `json:"ratio,omitempty"`
+	First  *Node   `json:"first,omitempty"`
+	Second *Node   `json:"second,omitempty"`
+}
+
+// Rect is an axis-aligned rectangle in pixels. Right and bottom are exclusive.
+type Rect struct {
+	Left, Top, Right, Bottom int
+}
+
+func (r Rect) contains(x, y int) bool {
+	return r.Left <= x && x < r.Right && r.Top <= y && y < r.Bottom
+}
+
+// NewLeaf returns a tree that is a single region.
+func NewLeaf() *Node {
+	return &Node{}
+}
+
+func (n *Node) IsLeaf() bool {
+	return n.Split == ""
+}
+
+// SplitLeaf turns the leaf into two leaves, the first one gets ratio of its
+// size.
+func (n *Node) SplitLeaf(o Orientation, ratio float64) error {
+	if !n.IsLeaf() {
+		return errors.New("only leaves can be split")
+	}
+	if o != LeftRight && o != TopBottom {
+		return fmt.Errorf("invalid split orientation %q", o)
+	}
+	n.Split = o
+	n.Ratio = clampRatio(ratio)
+	n.First = NewLeaf()
+	n.Second = NewLeaf()
+	return nil
+}
+
+// Merge turns the node into a leaf, dropping all of its children.
+func (n *Node) Merge() {
+	*n = Node{}
+}
+
+// Resize changes the ratio of a split node. It is clamped to MinRatio and
+// 1-MinRatio.
+func (n *Node) Resize(ratio float64) {
+	if !n.IsLeaf() {
+		n.Ratio = clampRatio(ratio)
+	}
+}
+
+// clampRatio limits r to MinRatio and 1-MinRatio. NaN, e.g. from dividing by
+// a size of 0, becomes an even split.
+func clampRatio(r float64) float64 {
+	if math.IsNaN(r) {
+		return 0.5
+	}
+	if r < MinRatio {
+		return MinRatio
+	}
+	if r > 1-MinRatio {
+		return 1 - MinRatio
+	}
+	return r
+}
+
+// Children returns the bounds of the node's children if it has the given
+// bounds.
+func (n *Node) Children(bounds Rect) (first, second Rect) {
+	first, second = bounds, bounds
+	if n.Split == LeftRight {
+		x := bounds.Left + int(float64(bounds.Right-bounds.Left)*n.Ratio+0.5)
+		first.Right, second.Left = x, x
+	} else {
+		y := bounds.Top + int(float64(bounds.Bottom-bounds.Top)*n.Ratio+0.5)
+		first.Bottom, second.Top = y, y
+	}
+	return first, second
+}
+
+// Leaf is a leaf of a tree together with its bounds.
+type Leaf struct {
+	Node   *Node
+	Bounds Rect
+}
+
+// Leaves returns all leaves of the tree with the given bounds, from the top
+// left to the bottom right.
+func (n *Node) Leaves(bounds Rect) []Leaf {
+	if n.IsLeaf() {
+		return []Leaf{{Node: n, Bounds: bounds}}
+	}
+	first, second := n.Children(bounds)
+	return append(n.First.Leaves(first), n.Second.Leaves(second)...)
+}
+
+// LeafAt returns the leaf under the point, if the tree has the given bounds.
+func (n *Node) LeafAt(bounds Rect, x, y int) (Leaf, bool) {
+	if !bounds.contains(x, y) {
+		return Leaf{}, false
+	}
+	if n.IsLeaf() {
+		return Leaf{Node: n, Bounds: bounds}, true
+	}
+	first, second := n.Children(bounds)
+	if first.contains(x, y) {
+		return n.First.LeafAt(first, x, y)
+	}
+	return n.Second.LeafAt(second, x, y)
+}
+
+// SplitAt returns the split node whose split line is at most tolerance pixels
+// away from the point, along with the bounds of that node. The deepest node
+// wins if lines are close to each other.
+func (n *Node) SplitAt(bounds Rect, x, y, tolerance int) (*Node, Rect, bool) {
+	if n.IsLeaf() || !bounds.contains(x, y) {
+		return nil, Rect{}, false
+	}
+	first, second := n.Children(bounds)
+	if found, r, ok := n.First.SplitAt(first, x, y, tolerance); ok {
+		return found, r, true
+	}
+	if found, r, ok := n.Second.SplitAt(second, x, y, tolerance); ok {
+		return found, r, true
+	}
+	d := y - first.Bottom
+	if n.Split == LeftRight {
+		d = x - first.Right
+	}
+	if -tolerance <= d && d <= tolerance {
+		return n, bounds, true
+	}
+	return nil, Rect{}, false
+}
+
+// RatioAt returns the ratio that puts the node's split line at the point if
+// the node has the given bounds. If the bounds have no size in the direction
+// of the split, the ratio stays as it is.
+func (n *Node) RatioAt(bounds Rect, x, y int) float64 {
+	pos, start, end := y, bounds.Top, bounds.Bottom
+	if n.Split == LeftRight {
+		pos, start, end = x, bounds.Left, bounds.Right
+	}
+	if end <= start {
+		return n.Ratio
+	}
+	return float64(pos-start) / float64(end-start)
+}
+
+// Parent returns the parent of the node in the tree, or nil if the node is the
+// root or not in the tree.
+func (n *Node) Parent(child *Node) *Node {
+	if n.IsLeaf() {
+		return nil
+	}
+	if n.First == child || n.Second == child {
+		return n
+	}
+	if p := n.First.Parent(child); p != nil {
+		return p
+	}
+	return n.Second.Parent(child)
+}
+
+// Copy returns a deep copy of the tree.
+func (n *Node) Copy() *Node {
+	c := *n
+	if !n.IsLeaf() {
+		c.First = n.First.Copy()
+		c.Second = n.Second.Copy()
+	}
+	return &c
+}
+
+// Validate checks a tree that was read from a file.
+func (n *Node) Validate() error {
+	if n.IsLeaf() {
+		if n.First != nil || n.Second != nil || n.Ratio != 0 {
+			return errors.New("a leaf must not have children or a ratio")
+		}
+		return nil
+	}
+	if n.Split != LeftRight && n.Split != TopBottom {
+		return fmt.Errorf(`invalid split %q, use "left-right" or "top-bottom"`, n.Split)
+	}
+	if !(MinRatio <= n.Ratio && n.Ratio <= 1-MinRatio) {
+		return fmt.Errorf("split ratio %g must be between %g and %g", n.Ratio, MinRatio, 1-MinRatio)
+	}
+	if n.First == nil || n.Second == nil {
+		return errors.New("a split must have two children")
+	}
+	if err := n.First.Validate(); err != nil {
+		return err
+	}
+	return n.Second.Validate()
+}
@@ -0,0 +1,286 @@
+package bsp
+
+import (
+	"math"
+	"testing"
+)
+
+// twoByOne returns a tree with a left half and a right half that is split
+// into a top and a bottom quarter.
+func twoByOne() *Node {
+	root := NewLeaf()
+	root.SplitLeaf(LeftRight, 0.5)
+	root.Second.SplitLeaf(TopBottom, 0.5)
+	return root
+}
+
+var area = Rect{Left: 0, Top: 0, Right: 1000, Bottom: 600}
+
+func TestSplitLeaf(t *testing.T) {
+	tests := []struct {
+		name      string
+		o         Orientation
+		ratio     float64
+		wantRatio float64
+		wantErr   bool
+	}{
+		{name: "left-right", o: LeftRight, ratio: 0.3, wantRatio: 0.3},
+		{name: "top-bottom", o: TopBottom, ratio: 0.7, wantRatio: 0.7},
+		{name: "ratio too small", o: LeftRight, ratio: 0, wantRatio: MinRatio},
+		{name: "ratio too large", o: LeftRight, ratio: 2, wantRatio: 1 - MinRatio},
+		{name: "ratio NaN", o: LeftRight, ratio: math.NaN(), wantRatio: 0.5},
+		{name: "invalid orientation", o: "diagonal", ratio: 0.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewLeaf()
+			err := n.SplitLeaf(tt.o, tt.ratio)
+			if tt.wantErr {
+				if err == nil || !n.IsLeaf() {
+					t.Fatalf("got %v and a split, want an error and a leaf", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n.IsLeaf() || !n.First.IsLeaf() || !n.Second.IsLeaf() {
+				t.Fatal("want a split with two leaves")
+			}
+			if n.Split != tt.o || n.Ratio != tt.wantRatio {
+				t.Errorf("got %s %g, want %s %g", n.Split, n.Ratio, tt.o, tt.wantRatio)
+			}
+		})
+	}
+}
+
+func TestSplitLeafOnlySplitsLeaves(t *testing.T) {
+	root := twoByOne()
+	if err := root.SplitLeaf(TopBottom, 0.5); err == nil {
+		t.Error("splitting a split node worked")
+	}
+	if root.Split != LeftRight {
+		t.Errorf("the split changed to %s", root.Split)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	root := twoByOne()
+	root.Second.Merge()
+	if !root.Second.IsLeaf() || root.Second.First != nil || root.Second.Ratio != 0 {
+		t.Errorf("merged node is %+v, want a leaf", root.Second)
+	}
+	if got := len(root.Leaves(area)); got != 2 {
+		t.Errorf("the tree has %d leaves, want 2", got)
+	}
+	if err := root.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLeaves(t *testing.T) {
+	tests := []struct {
+		name   string
+		tree   *Node
+		bounds Rect
+		want   []Rect
+	}{
+		{name: "leaf", tree: NewLeaf(), bounds: area, want: []Rect{area}},
+		{
+			name:   "nested",
+			tree:   twoByOne(),
+			bounds: area,
+			want: []Rect{
+				{0, 0, 500, 600},
+				{500, 0, 1000, 300},
+				{500, 300, 1000, 600},
+			},
+		},
+		{
+			name:   "offset and rounded",
+			tree:   twoByOne(),
+			bounds: Rect{Left: 100, Top: 50, Right: 201, Bottom: 151},
+			want: []Rect{
+				{100, 50, 151, 151},
+				{151, 50, 201, 101},
+				{151, 101, 201, 151},
+			},
+		},
+		{
+			name:   "no size",
+			tree:   twoByOne(),
+			bounds: Rect{},
+			want:   []Rect{{}, {}, {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaves := tt.tree.Leaves(tt.bounds)
+			if len(leaves) != len(tt.want) {
+				t.Fatalf("got %d leaves, want %d", len(leaves), len(tt.want))
+			}
+			for i, l := range leaves {
+				if l.Bounds != tt.want[i] {
+					t.Errorf("leaf %d is %v, want %v", i, l.Bounds, tt.want[i])
+				}
+				if !l.Node.IsLeaf() {
+					t.Errorf("leaf %d is split", i)
+				}
+			}
+		})
+	}
+}
+
+func TestLeafAt(t *testing.T) {
+	root := twoByOne()
+	tests := []struct {
+		x, y   int
+		want   Rect
+		wantOK bool
+	}{
+		{x: 0, y: 0, want: Rect{0, 0, 500, 600}, wantOK: true},
+		{x: 499, y: 599, want: Rect{0, 0, 500, 600}, wantOK: true},
+		{x: 500, y: 299, want: Rect{500, 0, 1000, 300}, wantOK: true},
+		{x: 999, y: 300, want: Rect{500, 300, 1000, 600}, wantOK: true},
+		{x: 1000, y: 10},
+		{x: -1, y: 10},
+		{x: 10, y: 600},
+	}
+	for _, tt := range tests {
+		l, ok := root.LeafAt(area, tt.x, tt.y)
+		if ok != tt.wantOK || l.Bounds != tt.want {
+			t.Errorf("%d,%d: got %v %v, want %v %v", tt.x, tt.y, l.Bounds, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSplitAt(t *testing.T) {
+	root := twoByOne()
+	tests := []struct {
+		name       string
+		x, y       int
+		want       *Node
+		wantBounds Rect
+	}{
+		{name: "on the vertical line", x: 502, y: 100, want: root, wantBounds: area},
+		{name: "on the nested line", x: 800, y: 297, want: root.Second, wantBounds: Rect{500, 0, 1000, 600}},
+		{name: "where the lines meet the deepest wins", x: 503, y: 300, want: root.Second, wantBounds: Rect{500, 0, 1000, 600}},
+		{name: "away from the lines", x: 200, y: 300},
+		{name: "outside", x: 2000, y: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, bounds, ok := root.SplitAt(area, tt.x, tt.y, 4)
+			if n != tt.want || ok != (tt.want != nil) || bounds != tt.wantBounds {
+				t.Errorf("got %p %v %v, want %p %v", n, bounds, ok, tt.want, tt.wantBounds)
+			}
+		})
+	}
+}
+
+func TestRatioAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		split  Orientation
+		ratio  float64
+		bounds Rect
+		x, y   int
+		want   float64
+	}{
+		{name: "left-right", split: LeftRight, ratio: 0.5, bounds: Rect{100, 0, 500, 100}, x: 200, y: 50, want: 0.25},
+		{name: "top-bottom", split: TopBottom, ratio: 0.5, bounds: Rect{0, 100, 100, 300}, x: 50, y: 250, want: 0.75},
+		{name: "no width", split: LeftRight, ratio: 0.3, bounds: Rect{100, 0, 100, 100}, x: 100, y: 50, want: 0.3},
+		{name: "no height", split: TopBottom, ratio: 0.4, bounds: Rect{0, 100, 100, 100}, x: 50, y: 100, want: 0.4},
+		{name: "negative size", split: LeftRight, ratio: 0.6, bounds: Rect{100, 0, 50, 100}, x: 70, y: 50, want: 0.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &Node{Split: tt.split, Ratio: tt.ratio, First: NewLeaf(), Second: NewLeaf()}
+			got := n.RatioAt(tt.bounds, tt.x, tt.y)
+			if got != tt.want {
+				t.Errorf("got %g, want %g", got, tt.want)
+			}
+			n.Resize(got)
+			if math.IsNaN(n.Ratio) || math.IsInf(n.Ratio, 0) {
+				t.Errorf("resizing to it gives ratio %g", n.Ratio)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	for _, tt := range []struct{ ratio, want float64 }{
+		{0.3, 0.3},
+		{-1, MinRatio},
+		{1, 1 - MinRatio},
+		{math.NaN(), 0.5},
+		{math.Inf(1), 1 - MinRatio},
+		{math.Inf(-1), MinRatio},
+	} {
+		n := twoByOne()
+		n.Resize(tt.ratio)
+		if n.Ratio != tt.want {
+			t.Errorf("resizing to %g gives %g, want %g", tt.ratio, n.Ratio, tt.want)
+		}
+	}
+	leaf := NewLeaf()
+	leaf.Resize(0.3)
+	if leaf.Ratio != 0 {
+		t.Errorf("resizing a leaf gave it ratio %g", leaf.Ratio)
+	}
+}
+
+func TestParent(t *testing.T) {
+	root := twoByOne()
+	tests := []struct {
+		name  string
+		child *Node
+		want  *Node
+	}{
+		{name: "root", child: root},
+		{name: "child of the root", child: root.First, want: root},
+		{name: "nested", child: root.Second.Second, want: root.Second},
+		{name: "not in the tree", child: NewLeaf()},
+	}
+	for _, tt := range tests {
+		if got := root.Parent(tt.child); got != tt.want {
+			t.Errorf("%s: got %p, want %p", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	root := twoByOne()
+	c := root.Copy()
+	c.Second.Resize(0.2)
+	c.First.SplitLeaf(TopBottom, 0.5)
+	if root.Second.Ratio != 0.5 || !root.First.IsLeaf() {
+		t.Error("changing the copy changed the original")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tree    *Node
+		wantErr bool
+	}{
+		{name: "leaf", tree: NewLeaf()},
+		{name: "nested", tree: twoByOne()},
+		{name: "leaf with ratio", tree: &Node{Ratio: 0.5}, wantErr: true},
+		{name: "leaf with child", tree: &Node{First: NewLeaf()}, wantErr: true},
+		{name: "invalid split", tree: &Node{Split: "diagonal", Ratio: 0.5, First: NewLeaf(), Second: NewLeaf()}, wantErr: true},
+		{name: "ratio too small", tree: &Node{Split: LeftRight, Ratio: 0.01, First: NewLeaf(), Second: NewLeaf()}, wantErr: true},
+		{name: "ratio NaN", tree: &Node{Split: LeftRight, Ratio: math.NaN(), First: NewLeaf(), Second: NewLeaf()}, wantErr: true},
+		{name: "missing child", tree: &Node{Split: TopBottom, Ratio: 0.5, First: NewLeaf()}, wantErr: true},
+		{name: "invalid grandchild", tree: &Node{Split: TopBottom, Ratio: 0.5, First: NewLeaf(), Second: &Node{Ratio: 1}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tree.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want an error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const usage = `usage: tile_screen [command] [flags]
+
+Without a command, the overlay comes up for the active window.
+
+Commands:
+  preview         render the overlay into a PNG image
+  export-layout   write a layout as JSON for sharing or as SVG for docs
+  import-layout   validate a shared JSON layout and add it to the config
+  resident        keep running and snap windows dragged by their title bar
+  list-monitors   show the monitors with the ids used in the config
+  save-workspace  remember where the windows on the current desktop are
+  restore-workspace
+                  put the windows back to where they were saved
+  move, grow, swap <direction>
+                  move the active window one tile, grow it by one tile or
+                  swap it with the window next to it, direction is left,
+                  right, up or down
+  send next|previous
+                  move the active window to the same tiles on the next or
+                  previous monitor
+  control         send commands to tile_screen running in resident mode
+
+Use "tile_screen <command> -h" for the flags of a command.
+`
+
+// runCommand runs the command line interface. The output goes to stdout,
+// errors are returned.
+func runCommand(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command given\n\n%s", usage)
+	}
+	switch args[0] {
+	case "preview":
+		return previewCommand(args[1:], stdout)
+	case "export-layout":
+		return exportLayoutCommand(args[1:], stdout)
+	case "import-layout":
+		return importLayoutCommand(args[1:], stdout)
+	case "resident":
+		return residentCommand(args[1:])
+	case "list-monitors":
+		return listMonitorsCommand(args[1:], stdout)
+	case "save-workspace":
+		return saveWorkspaceCommand(args[1:], stdout)
+	case "restore-workspace":
+		return restoreWorkspaceCommand(args[1:], stdout)
+	case "control":
+		return controlCommand(args[1:], stdout)
+	case "move", "grow", "swap", "send":
+		if len(args) != 2 {
+			if args[0] == "send" {
+				return fmt.Errorf("usage: tile_screen send next|previous")
+			}
+			return fmt.Errorf("usage: tile_screen %s left|right|up|down", args[0])
+		}
+		c, err := parseTileCommand(args[0] + " " + args[1])
+		if err != nil {
+			return err
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		return runTileCommand(c, cfg)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+}
+
+// maxPreviewSize limits the preview images, the whole image is kept in
+// memory.
+const maxPreviewSize = 8192
+
+func previewCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("preview", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	gridFlag := flags.String("grid", "2x2", "grid size as columns x rows")
+	sizeFlag := flags.String("size", "1920x1080", "work area size in pixels")
+	themeFlag := flags.String("theme", "light", "theme name, built-in or from the config")
+	selectFlag := flags.String("select", "", `selected tiles, e.g. "1,1-2,1" from column 1 row 1 to column 2 row 1`)
+	out := flags.String("o", "layout.png", "output PNG file, or directory with -all")
+	all := flags.Bool("all", false, "render every grid from 2x2 to 9x9 in every theme into the directory given by -o")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	width, height, err := parseDims(*sizeFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -size: %w", err)
+	}
+	if width > maxPreviewSize || height > maxPreviewSize {
+		return fmt.Errorf("invalid -size: %s must be at most %dx%d", *sizeFlag, maxPreviewSize, maxPreviewSize)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if *all {
+		if err := os.MkdirAll(*out, 0777); err != nil {
+			return err
+		}
+		names := themeNames(cfg)
+		for n := 2; n <= 9; n++ {
+			for _, name := range names {
+				t, err := resolveTheme(name, cfg.Themes, false, false)
+				if err != nil {
+					return err
+				}
+				img := renderPreview(width, height, n, n, t, nil)
+				path := filepath.Join(*out, fmt.Sprintf("%dx%d-%s.png", n, n, name))
+				if err := writePNG(path, img); err != nil {
+					return err
+				}
+				fmt.Fprintln(stdout, path)
+			}
+		}
+		return nil
+	}
+
+	cols, rows, err := parseDims(*gridFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -grid: %w", err)
+	}
+	if cols > maxGridSize || rows > maxGridSize {
+		return fmt.Errorf("invalid -grid: %s must be at most %dx%d", *gridFlag, maxGridSize, maxGridSize)
+	}
+	t, err := resolveTheme(*themeFlag, cfg.Themes, false, false)
+	if err != nil {
+		return err
+	}
+	var selected *rect
+	if *selectFlag != "" {
+		tiles, err := parseTileRange(*selectFlag, cols, rows)
+		if err != nil {
+			return fmt.Errorf("invalid -select: %w", err)
+		}
+		selected = &tiles
+	}
+	return writePNG(*out, renderPreview(width, height, cols, rows, t, selected))
+}
+
+func exportLayoutCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export-layout", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	gridFlag := flags.String("grid", "", "grid size as columns x rows, the last used grid by default")
+	layoutFlag := flags.String("layout", "", "name of a layout from the config to export instead of a grid")
+	nameFlag := flags.String("name", "", "name of the exported layout")
+	sizeFlag := flags.String("size", "1920x1080", "work area size in pixels for SVG")
+	themeFlag := flags.String("theme", "light", "theme for SVG, built-in or from the config")
+	formatFlag := flags.String("format", "", `"json" or "svg", by default taken from the -o file extension, else json`)
+	out := flags.String("o", "", "output file, standard output by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var l layout
+	switch {
+	case *layoutFlag != "":
+		var ok bool
+		l, ok = cfg.findLayout(*layoutFlag)
+		if !ok {
+			return fmt.Errorf("there is no layout %q in the config", *layoutFlag)
+		}
+	case *gridFlag != "":
+		l.Cols, l.Rows, err = parseDims(*gridFlag)
+		if err != nil {
+			return fmt.Errorf("invalid -grid: %w", err)
+		}
+	default:
+		l.Cols, l.Rows = loadGridSize()
+	}
+	if *nameFlag != "" {
+		l.Name = *nameFlag
+	}
+	if l.Name == "" {
+		l.Name = fmt.Sprintf("%dx%d grid", l.Cols, l.Rows)
+	}
+	if err := l.validate(); err != nil {
+		return err
+	}
+	if l.Tree != nil {
+		return fmt.Errorf("layout %q was built by splitting, only grid layouts can be exported", l.Name)
+	}
+
+	format := strings.ToLower(*formatFlag)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*out)), ".")
+	}
+	if format != "svg" {
+		format = "json"
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == "svg" {
+		width, height, err := parseDims(*sizeFlag)
+		if err != nil {
+			return fmt.Errorf("invalid -size: %w", err)
+		}
+		t, err := resolveTheme(*themeFlag, cfg.Themes, false, false)
+		if err != nil {
+			return err
+		}
+		return writeLayoutSVG(w, l, width, height, t)
+	}
+	return writeLayoutJSON(w, l)
+}
+
+func importLayoutCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import-layout", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	nameFlag := flags.String("name", "", "install the layout under this name instead of its own")
+	activate := flags.Bool("activate", false, "use the layout the next time the overlay comes up")
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, "usage: tile_screen import-layout [flags] <file.json or - for standard input>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("import-layout needs exactly one file")
+	}
+
+	var r io.Reader = os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	l, err := readLayoutJSON(r)
+	if err != nil {
+		return err
+	}
+	if *nameFlag != "" {
+		l.Name = *nameFlag
+	}
+	if err := installLayout(l); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "installed layout %q (%dx%d) in %s\n", l.Name, l.Cols, l.Rows, configPath())
+	if *activate {
+		return saveGridSize(l.Cols, l.Rows)
+	}
+	return nil
+}
+
+func controlCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("control", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.Usage = func() {
+		fmt.Fprint(stdout, `usage: tile_screen control [request...]
+
+Sends each request to tile_screen running in resident mode and prints the
+responses, one JSON object per line. A request is a JSON object like
+{"command":"place","tiles":"1,1-2,1","grid":"3x2"} or just the name of a
+command without arguments, e.g. list-windows. Without requests, they are read
+from standard input, one per line.
+
+Commands: place, list-windows, list-monitors, save-workspace,
+restore-workspace, show-overlay
+`)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var requests []string
+	for _, arg := range flags.Args() {
+		if !strings.HasPrefix(strings.TrimSpace(arg), "{") {
+			arg = fmt.Sprintf(`{"command":%q}`, arg)
+		}
+		requests = append(requests, arg)
+	}
+	if len(requests) == 0 {
+		lines := bufio.NewScanner(os.Stdin)
+		lines.Buffer(nil, maxIPCLine)
+		for lines.Scan() {
+			if line := strings.TrimSpace(lines.Text()); line != "" {
+				requests = append(requests, line)
+			}
+		}
+		if err := lines.Err(); err != nil {
+			return err
+		}
+	}
+	// A request spanning lines would be taken as several requests.
+	for i, req := range requests {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(req)); err != nil {
+			return fmt.Errorf("request %d is not valid JSON: %w", i+1, err)
+		}
+		requests[i] = compact.String()
+	}
+	return sendIPCRequests(requests, stdout)
+}
+
+// renderPreview draws the overlay of the given size into an image. If tiles
+// is not nil, they are shown as selected, with the preview of where a window
+// would go.
+func renderPreview(width, height, cols, rows int, t theme, tiles *rect) *image.RGBA {
+	view := overlayView{
+		width:     width,
+		height:    height,
+		cols:      cols,
+		rows:      rows,
+		selection: noSelection,
+		theme:     t,
+	}
+	if tiles != nil {
+		// Selections are mouse positions, so right and bottom are inclusive.
+		view.selection = tilesToPixels(*tiles, width, height, cols, rows)
+		view.selection.right--
+		view.selection.bottom--
+		view.preview, view.hasPreview = fitSizeLimits(
+			*tiles, width, height, cols, rows, sizeLimits{}, growIntoTiles,
+		)
+	}
+	c := newImageCanvas(width, height)
+	drawOverlay(c, view)
+	return c.img
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// themeNames returns the built-in and custom theme names, sorted.
+func themeNames(c config) []string {
+	var names []string
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	for name := range c.Themes {
+		if _, ok := builtinThemes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseDims parses sizes like "3x2" or "1920x1080". Both values must be
+// positive.
+func parseDims(s string) (int, int, error) {
+	parts := strings.Split(strings.ToLower(s), "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%q is not of the form AxB", s)
+	}
+	a, errA := strconv.Atoi(strings.TrimSpace(parts[0]))
+	b, errB := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if errA != nil || errB != nil || a <= 0 || b <= 0 {
+		return 0, 0, fmt.Errorf("%q is not of the form AxB with positive numbers", s)
+	}
+	return a, b, nil
+}
+
+// parseTileRange parses "col,row-col,row" with 1-based, inclusive tile
+// coordinates, or "col,row" for a single tile. It returns the tiles in tile
+// units.
+func parseTileRange(s string, cols, rows int) (rect, error) {
+	corners := strings.Split(s, "-")
+	if len(corners) > 2 {
+		return rect{}, fmt.Errorf("%q is not of the form col,row-col,row", s)
+	}
+	var xs, ys []int
+	for _, corner := range corners {
+		parts := strings.Split(corner, ",")
+		if len(parts) != 2 {
+			return rect{}, fmt.Errorf("%q is not of the form col,row-col,row", s)
+		}
+		x, errX := strconv.Atoi(strings.TrimSpace(parts[0]))
+		y, errY := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if errX != nil || errY != nil || x < 1 || y < 1 || x > cols || y > rows {
+			return rect{}, fmt.Errorf("tile %q is not in the %dx%d grid", corner, cols, rows)
+		}
+		xs = append(xs, x-1)
+		ys = append(ys, y-1)
+	}
+	if len(xs) == 1 {
+		xs = append(xs, xs[0])
+		ys = append(ys, ys[0])
+	}
+	return rect{
+		left:   min(xs[0], xs[1]),
+		top:    min(ys[0], ys[1]),
+		right:  max(xs[0], xs[1]) + 1,
+		bottom: max(ys[0], ys[1]) + 1,
+	}, nil
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// config is the user's configuration. It is stored as JSON in the config
+// directory and users edit it by hand. The program itself only writes it to
+// install imported layouts, keeping everything else as it is. Missing fields
+// keep their default values.
+type config struct {
+	// MinSizeStrategy decides what to do with windows that do not fit into the
+	// selected tiles because of their minimum size. It is one of:
+	//
+	//     "grow"   add neighbouring tiles until the window fits (default)
+	//     "clamp"  enlarge the window as needed but keep it in the work area
+	//     "refuse" do not move the window and show a message in the overlay
+	MinSizeStrategy string `json:"minSizeStrategy"`
+
+	// IgnoreClasses lists window classes that are never moved, e.g. those of
+	// games. The taskbar and the desktop are always ignored.
+	IgnoreClasses []string `json:"ignoreClasses"`
+
+	// MoveFullscreen allows moving borderless windows that cover the whole
+	// monitor. By default they are left alone.
+	MoveFullscreen bool `json:"moveFullscreen"`
+
+	// OverlayOpacity is the opacity of the grid lines, the selection and the
+	// preview outline in percent, 100 makes them opaque. The default is 75.
+	// Nothing else is drawn, the desktop shows through between them.
+	OverlayOpacity int `json:"overlayOpacity"`
+
+	// Theme is the name of the overlay theme: "light", "dark",
+	// "high-contrast" or one of Themes. The default "auto" follows the
+	// system's dark mode and high contrast settings.
+	Theme string `json:"theme"`
+
+	// Themes are custom themes. Each starts out as a copy of its "base" theme
+	// and overrides the given fields, e.g.
+	//
+	//     "themes": {"mine": {"base": "dark", "selection": "#ff000080"}}
+	Themes map[string]json.RawMessage `json:"themes"`
+
+	// Mouse maps mouse buttons and modifier keys to what a drag does, the
+	// defaults are:
+	//
+	//     "mouse": {
+	//         "left": "place",
+	//         "right": "place-full-height",
+	//         "middle": "place-tile",
+	//         "ctrl": "toggle-gaps",
+	//         "shift": "move-only"
+	//     }
+	//
+	// Buttons can be "place" (into all touched tiles), "place-full-height"
+	// (into the touched columns, from top to bottom) or "place-tile" (into
+	// the tile under the mouse when releasing it). Modifiers can be
+	// "toggle-gaps", "move-only" (keep the window size) or "" for nothing.
+	// Pressing another button or Escape during a drag cancels it.
+	Mouse inputBindings `json:"mouse"`
+
+	// Gap is the space in pixels between windows when using gaps. Gaps is
+	// whether to use them by default, the toggle-gaps modifier inverts it.
+	Gap  int  `json:"gap"`
+	Gaps bool `json:"gaps"`
+
+	// FillOrder is how the windows selected in the overlay's window list are
+	// distributed over the grid: "rows" (default), "columns" or
+	// "largest-first". The O key in the overlay switches between them.
+	FillOrder string `json:"fillOrder"`
+
+	// AutoTile keeps all windows tiled while the program runs in resident
+	// mode. It is the layout to use: "master-stack", "columns" or "bsp". The
+	// default "" turns auto-tiling off. MasterRatio is the part of the width
+	// that the master window gets in the master-stack layout, 0.6 by
+	// default.
+	AutoTile    string  `json:"autoTile"`
+	MasterRatio float64 `json:"masterRatio"`
+
+	// Hotkeys map key combinations to commands for the active window while
+	// the program runs in resident mode. Commands are "move", "grow" or
+	// "swap" followed by "left", "right", "up" or "down", or "send next" and
+	// "send previous" to move the window to another monitor. By default
+	// Ctrl+Alt+arrow moves the window one tile, Ctrl+Alt+Shift+arrow swaps it
+	// with its neighbour and Ctrl+Alt+PageDown/PageUp sends it to the
+	// next/previous monitor. Set a hotkey to "" to turn it off, e.g.
+	//
+	//     "hotkeys": {"ctrl+alt+left": "", "win+shift+h": "grow left"}
+	Hotkeys map[string]string `json:"hotkeys"`
+
+	// MonitorGrids are the grids of the tile commands for each monitor, e.g.
+	//
+	//     "monitorGrids": {"DEL4109/UID4353": "4x2", "1920,0 1920x1080": "3x3"}
+	//
+	// The list-monitors command shows the ids of all monitors. They are made
+	// of the monitor model and the port it is on, so they do not change
+	// after rebooting. A model alone, like "DEL4109", also works if there is
+	// only one monitor of that model. Monitors that Windows does not know the
+	// model of are identified by position and resolution. Device names like
+	// "\\\\.\\DISPLAY2" from older versions still work but they can change
+	// when plugging in monitors. Monitors that are not listed use the grid
+	// that was last chosen in the overlay.
+	MonitorGrids map[string]string `json:"monitorGrids"`
+
+	// SnapModifier is the key to hold while dragging a window by its title bar
+	// to snap it to the grid, "ctrl" (default), "shift" or "alt". This only
+	// works while the program runs in resident mode.
+	SnapModifier string `json:"snapModifier"`
+
+	// Layouts are grids that the overlay cycles through with the L key. They
+	// can be shared with the export-layout and import-layout commands.
+	// Layouts saved with the S key after splitting the work area in the
+	// overlay (B, then H and V) have a "tree" instead of "cols" and "rows".
+	// Layouts with "desktops", e.g. ["2", "Work"], are only offered on these
+	// virtual desktops, by number or name.
+	Layouts []layout `json:"layouts"`
+
+	// LogLevel is the minimum level of messages written to the log file in
+	// the config directory: "debug", "info" (default), "warn" or "error".
+	LogLevel string `json:"logLevel"`
+}
+
+func defaultConfig() config {
+	return config{
+		MinSizeStrategy: "grow",
+		OverlayOpacity:  75,
+		Theme:           "auto",
+		Mouse:           defaultInputBindings(),
+		Gap:             8,
+		FillOrder:       "rows",
+		MasterRatio:     0.6,
+		Hotkeys:         defaultHotkeys(),
+		SnapModifier:    "ctrl",
+		LogLevel:        "info",
+	}
+}
+
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.Getenv("APPDATA")
+	}
+	return filepath.Join(dir, "tile_screen")
+}
+
+func configPath() string {
+	return filepath.Join(configDir(), "config.json")
+}
+
+// loadConfig reads the config file. If there is no config file, the default
+// config is returned without an error. If the file is invalid, the default
+// config is returned along with the error.
+func loadConfig() (config, error) {
+	c := defaultConfig()
+	data, err := ioutil.ReadFile(configPath())
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return defaultConfig(), fmt.Errorf("invalid config file %s: %w", configPath(), err)
+	}
+	if _, err := c.sizeStrategy(); err != nil {
+		return defaultConfig(), err
+	}
+	for _, darkMode := range []bool{false, true} {
+		if _, err := resolveTheme(c.Theme, c.Themes, darkMode, false); err != nil {
+			return defaultConfig(), err
+		}
+	}
+	if err := c.Mouse.validate(); err != nil {
+		return defaultConfig(), err
+	}
+	if c.Gap < 0 {
+		return defaultConfig(), fmt.Errorf("invalid gap %d, it must not be negative", c.Gap)
+	}
+	if _, err := parseFillOrder(c.FillOrder); err != nil {
+		return defaultConfig(), err
+	}
+	if c.AutoTile != "" {
+		if _, err := parseAutoLayout(c.AutoTile); err != nil {
+			return defaultConfig(), err
+		}
+	}
+	if c.MasterRatio < 0.1 || c.MasterRatio > 0.9 {
+		return defaultConfig(), fmt.Errorf(
+			"invalid masterRatio %g, it must be between 0.1 and 0.9",
+			c.MasterRatio,
+		)
+	}
+	for key, command := range c.Hotkeys {
+		if _, err := parseHotkey(key); err != nil {
+			return defaultConfig(), err
+		}
+		if command == "" {
+			continue
+		}
+		if _, err := parseTileCommand(command); err != nil {
+			return defaultConfig(), fmt.Errorf("hotkey %q: %w", key, err)
+		}
+	}
+	for monitor, grid := range c.MonitorGrids {
+		cols, rows, err := parseDims(grid)
+		if err != nil {
+			return defaultConfig(), fmt.Errorf("monitorGrids %q: %w", monitor, err)
+		}
+		if cols > maxGridSize || rows > maxGridSize {
+			return defaultConfig(), fmt.Errorf(
+				"monitorGrids %q: grid %s must be at most %dx%d",
+				monitor, grid, maxGridSize, maxGridSize,
+			)
+		}
+	}
+	if _, err := parseModifier(c.SnapModifier); err != nil {
+		return defaultConfig(), fmt.Errorf("snapModifier: %w", err)
+	}
+	for _, l := range c.Layouts {
+		if err := l.validate(); err != nil {
+			return defaultConfig(), err
+		}
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		return defaultConfig(), err
+	}
+	return c, nil
+}
+
+func (c config) sizeStrategy() (sizeStrategy, error) {
+	switch c.MinSizeStrategy {
+	case "grow":
+		return growIntoTiles, nil
+	case "clamp":
+		return keepInWorkArea, nil
+	case "refuse":
+		return refuseToPlace, nil
+	}
+	return 0, fmt.Errorf(
+		`invalid minSizeStrategy %q, use "grow", "clamp" or "refuse"`,
+		c.MinSizeStrategy,
+	)
+}
+
+// overlayAlpha returns the overlay opacity as an alpha value. It never makes
+// the overlay completely invisible.
+func (c config) overlayAlpha() byte {
+	percent := c.OverlayOpacity
+	if percent < 10 {
+		percent = 10
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return byte(percent * 255 / 100)
+}
+
+// installLayout adds the layout to the config file, replacing any layout of
+// the same name. All other settings in the file are kept. A replaced layout
+// keeps its desktops unless the new one has its own, shared layout files do
+// not have desktops.
+func installLayout(l layout) error {
+	raw := map[string]json.RawMessage{}
+	data, err := ioutil.ReadFile(configPath())
+	if err == nil {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("invalid config file %s: %w", configPath(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var layouts []layout
+	if data, ok := raw["layouts"]; ok {
+		if err := json.Unmarshal(data, &layouts); err != nil {
+			return fmt.Errorf("invalid layouts in config file %s: %w", configPath(), err)
+		}
+	}
+	replaced := false
+	for i := range layouts {
+		if layouts[i].Name == l.Name {
+			if l.Desktops == nil {
+				l.Desktops = layouts[i].Desktops
+			}
+			layouts[i] = l
+			replaced = true
+		}
+	}
+	if !replaced {
+		layouts = append(layouts, l)
+	}
+	raw["layouts"], err = json.Marshal(layouts)
+	if err != nil {
+		return err
+	}
+
+	data, err = json.MarshalIndent(raw, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir(), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(configPath(), append(data, '\n'), 0666)
+}
+
+// layoutsOn returns the layouts that are available on the virtual desktop.
+func (c config) layoutsOn(vd virtualDesktops, desktop string) []layout {
+	var layouts []layout
+	for _, l := range c.Layouts {
+		if l.availableOn(vd, desktop) {
+			layouts = append(layouts, l)
+		}
+	}
+	return layouts
+}
+
+// findLayout returns the layout of the given name from the config.
+func (c config) findLayout(name string) (layout, bool) {
+	for _, l := range c.Layouts {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return layout{}, false
+}
+
+// monitorGrid returns the grid for monitor m, see MonitorGrids. all are the
+// identities of all monitors, they are needed to tell which monitor a model
+// refers to. Keys are tried in sorted order so the result does not depend on
+// map order if several keys match.
+func (c config) monitorGrid(m monitorIdentity, all []monitorIdentity) (cols, rows int) {
+	var keys []string
+	for key := range c.MonitorGrids {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if i, ok := matchMonitor(key, all); ok && all[i] == m {
+			if cols, rows, err := parseDims(c.MonitorGrids[key]); err == nil {
+				return cols, rows
+			}
+		}
+	}
+	return loadGridSize()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestInstallLayout(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APPDATA", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if err := os.MkdirAll(configDir(), 0777); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(configPath(), []byte(`{
+		"gap": 12,
+		"layouts": [
+			{"name": "halves", "cols": 2, "rows": 1, "desktops": ["2", "work"]},
+			{"name": "quarters", "cols": 2, "rows": 2}
+		]
+	}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	install := func(l layout) {
+		t.Helper()
+		if err := installLayout(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(want []layout) {
+		t.Helper()
+		data, err := os.ReadFile(configPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c struct {
+			Gap     int      `json:"gap"`
+			Layouts []layout `json:"layouts"`
+		}
+		if err := json.Unmarshal(data, &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Gap != 12 {
+			t.Errorf("the gap changed to %d", c.Gap)
+		}
+		if !reflect.DeepEqual(c.Layouts, want) {
+			t.Errorf("got layouts %+v, want %+v", c.Layouts, want)
+		}
+	}
+
+	// An imported layout has no desktops, the replaced one keeps them.
+	install(layout{Name: "halves", Cols: 3, Rows: 1})
+	check([]layout{
+		{Name: "halves", Cols: 3, Rows: 1, Desktops: []string{"2", "work"}},
+		{Name: "quarters", Cols: 2, Rows: 2},
+	})
+
+	install(layout{Name: "halves", Cols: 3, Rows: 1, Desktops: []string{"1"}})
+	check([]layout{
+		{Name: "halves", Cols: 3, Rows: 1, Desktops: []string{"1"}},
+		{Name: "quarters", Cols: 2, Rows: 2},
+	})
+
+	install(layout{Name: "thirds", Cols: 3, Rows: 1})
+	check([]layout{
+		{Name: "halves", Cols: 3, Rows: 1, Desktops: []string{"1"}},
+		{Name: "quarters", Cols: 2, Rows: 2},
+		{Name: "thirds", Cols: 3, Rows: 1},
+	})
+}
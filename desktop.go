@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// virtualDesktops gives access to the virtual desktops of Windows 10 and
+// later. Desktops are identified by their GUID, like
+// "{3F1C0A5E-6D4B-4C1A-9E0F-2B7A1D5C8E90}".
+type virtualDesktops interface {
+	// current returns the desktop that the user sees.
+	current() string
+	// all returns all desktops in the order of the task view.
+	all() []string
+	// name returns the name of the desktop as shown in the task view.
+	name(desktop string) string
+	// onCurrent reports whether the window shows on the current desktop,
+	// this includes windows pinned to all desktops.
+	onCurrent(window uintptr) bool
+	// windowDesktop returns the desktop that the window is on, or "" for
+	// pinned windows and if it is unknown.
+	windowDesktop(window uintptr) string
+}
+
+// singleDesktop is used if there are no virtual desktops, e.g. on older
+// versions of Windows. All windows are on its only desktop, which has the
+// id "".
+type singleDesktop struct{}
+
+func (singleDesktop) current() string              { return "" }
+func (singleDesktop) all() []string                { return []string{""} }
+func (singleDesktop) name(string) string           { return "Desktop 1" }
+func (singleDesktop) onCurrent(uintptr) bool       { return true }
+func (singleDesktop) windowDesktop(uintptr) string { return "" }
+
+// desktopName returns the name of the desktop in the task view, which is
+// "Desktop 2" for the second desktop unless the user renamed it.
+func desktopName(desktops []string, desktop, customName string) string {
+	if customName != "" {
+		return customName
+	}
+	for i, d := range desktops {
+		if d == desktop {
+			return fmt.Sprintf("Desktop %d", i+1)
+		}
+	}
+	return desktop
+}
+
+// findDesktop returns the desktop that the user refers to in the config or
+// on the command line. This is its number in the task view, starting at 1,
+// its name or its id.
+func findDesktop(vd virtualDesktops, key string) (string, error) {
+	all := vd.all()
+	if n, err := strconv.Atoi(key); err == nil {
+		if n < 1 || n > len(all) {
+			return "", fmt.Errorf("there is no desktop %d, there are %d desktops", n, len(all))
+		}
+		return all[n-1], nil
+	}
+	for _, d := range all {
+		if strings.EqualFold(key, d) || strings.EqualFold(key, vd.name(d)) {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("there is no desktop %q", key)
+}
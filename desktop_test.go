@@ -0,0 +1,220 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	workDesktop  = "{3F1C0A5E-6D4B-4C1A-9E0F-2B7A1D5C8E90}"
+	gamesDesktop = "{8A2D4C6E-1B3F-4E5A-9C7D-0F1E2D3C4B5A}"
+	thirdDesktop = "{C5B4A392-8170-4F6E-AD5C-4B3A29180706}"
+)
+
+// fakeDesktops are virtual desktops for tests. windows maps window handles
+// to their desktop, windows that are not in it are pinned.
+type fakeDesktops struct {
+	desktops []string
+	names    map[string]string
+	active   string
+	windows  map[uintptr]string
+}
+
+func newFakeDesktops() *fakeDesktops {
+	return &fakeDesktops{
+		desktops: []string{workDesktop, gamesDesktop, thirdDesktop},
+		names:    map[string]string{workDesktop: "Work", gamesDesktop: "Games"},
+		active:   workDesktop,
+		windows:  map[uintptr]string{1: workDesktop, 2: gamesDesktop},
+	}
+}
+
+func (f *fakeDesktops) current() string { return f.active }
+func (f *fakeDesktops) all() []string   { return f.desktops }
+
+func (f *fakeDesktops) name(desktop string) string {
+	return desktopName(f.desktops, desktop, f.names[desktop])
+}
+
+func (f *fakeDesktops) onCurrent(window uintptr) bool {
+	d := f.windowDesktop(window)
+	return d == "" || d == f.active
+}
+
+func (f *fakeDesktops) windowDesktop(window uintptr) string {
+	return f.windows[window]
+}
+
+func TestDesktopName(t *testing.T) {
+	vd := newFakeDesktops()
+	tests := []struct {
+		desktop string
+		want    string
+	}{
+		{desktop: workDesktop, want: "Work"},
+		{desktop: thirdDesktop, want: "Desktop 3"},
+		{desktop: "{00000000-0000-0000-0000-000000000000}", want: "{00000000-0000-0000-0000-000000000000}"},
+	}
+	for _, tt := range tests {
+		if got := vd.name(tt.desktop); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.desktop, got, tt.want)
+		}
+	}
+	if got := (singleDesktop{}).name(""); got != "Desktop 1" {
+		t.Errorf("the single desktop is %q", got)
+	}
+}
+
+func TestFindDesktop(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "1", want: workDesktop},
+		{key: "3", want: thirdDesktop},
+		{key: "0", wantErr: true},
+		{key: "4", wantErr: true},
+		{key: "games", want: gamesDesktop},
+		{key: "Desktop 3", want: thirdDesktop},
+		{key: "desktop 2", wantErr: true},
+		{key: gamesDesktop, want: gamesDesktop},
+		{key: "{3f1c0a5e-6d4b-4c1a-9e0f-2b7a1d5c8e90}", want: workDesktop},
+		{key: "Music", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := findDesktop(newFakeDesktops(), tt.key)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%q: got %q %v, want %q, error: %v", tt.key, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLayoutsOn(t *testing.T) {
+	cfg := config{Layouts: []layout{
+		{Name: "everywhere", Cols: 2, Rows: 2},
+		{Name: "work", Cols: 3, Rows: 2, Desktops: []string{"Work"}},
+		{Name: "games and third", Cols: 1, Rows: 1, Desktops: []string{"2", thirdDesktop}},
+		{Name: "gone", Cols: 4, Rows: 4, Desktops: []string{"7", "Music"}},
+	}}
+	tests := []struct {
+		desktop string
+		want    []string
+	}{
+		{desktop: workDesktop, want: []string{"everywhere", "work"}},
+		{desktop: gamesDesktop, want: []string{"everywhere", "games and third"}},
+		{desktop: thirdDesktop, want: []string{"everywhere", "games and third"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, l := range cfg.layoutsOn(newFakeDesktops(), tt.desktop) {
+			got = append(got, l.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", newFakeDesktops().name(tt.desktop), got, tt.want)
+		}
+	}
+}
+
+func TestLayoutsWithoutVirtualDesktops(t *testing.T) {
+	cfg := config{Layouts: []layout{
+		{Name: "everywhere", Cols: 2, Rows: 2},
+		{Name: "first", Cols: 3, Rows: 2, Desktops: []string{"1"}},
+		{Name: "second", Cols: 1, Rows: 1, Desktops: []string{"2"}},
+	}}
+	var got []string
+	for _, l := range cfg.layoutsOn(singleDesktop{}, "") {
+		got = append(got, l.Name)
+	}
+	if want := []string{"everywhere", "first"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFindWorkspace(t *testing.T) {
+	all := []workspace{
+		{Name: "coding", Desktop: workDesktop},
+		{Name: "Coding", Desktop: gamesDesktop},
+		{Name: "default", Desktop: gamesDesktop},
+	}
+	tests := []struct {
+		name, desktop string
+		want          int
+	}{
+		{name: "coding", desktop: workDesktop, want: 0},
+		{name: "coding", desktop: gamesDesktop, want: 1},
+		{name: "DEFAULT", desktop: gamesDesktop, want: 2},
+		{name: "default", desktop: workDesktop, want: -1},
+		{name: "coding", desktop: thirdDesktop, want: -1},
+	}
+	for _, tt := range tests {
+		got, ok := findWorkspace(all, tt.name, tt.desktop)
+		if tt.want == -1 {
+			if ok {
+				t.Errorf("%s on %s: found %+v", tt.name, tt.desktop, got)
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(got, all[tt.want]) {
+			t.Errorf("%s on %s: got %+v %v, want %+v", tt.name, tt.desktop, got, ok, all[tt.want])
+		}
+	}
+}
+
+func TestMatchWindows(t *testing.T) {
+	saved := []savedWindow{
+		{App: "code.exe", Class: "Chrome_WidgetWin_1", Title: "main.go - tile_screen"},
+		{App: "code.exe", Class: "Chrome_WidgetWin_1", Title: "notes.md - notes"},
+		{App: "firefox.exe", Class: "MozillaWindowClass", Title: "Go Documentation"},
+		{App: "mspaint.exe", Class: "MSPaintApp", Title: "Untitled - Paint"},
+	}
+	tests := []struct {
+		name string
+		open []openWindow
+		want []int
+	}{
+		{
+			name: "same titles",
+			open: []openWindow{
+				{app: "firefox.exe", class: "MozillaWindowClass", title: "Go Documentation"},
+				{app: "code.exe", class: "Chrome_WidgetWin_1", title: "notes.md - notes"},
+				{app: "code.exe", class: "Chrome_WidgetWin_1", title: "main.go - tile_screen"},
+			},
+			want: []int{2, 1, 0, -1},
+		},
+		{
+			name: "exact titles first, then other titles",
+			open: []openWindow{
+				{app: "CODE.EXE", class: "Chrome_WidgetWin_1", title: "display.go - tile_screen"},
+				{app: "code.exe", class: "Chrome_WidgetWin_1", title: "notes.md - notes"},
+			},
+			want: []int{0, 1, -1, -1},
+		},
+		{
+			name: "every window once",
+			open: []openWindow{
+				{app: "code.exe", class: "Chrome_WidgetWin_1", title: "Welcome"},
+			},
+			want: []int{0, -1, -1, -1},
+		},
+		{
+			name: "other class",
+			open: []openWindow{
+				{app: "firefox.exe", class: "MozillaDialogClass", title: "Go Documentation"},
+			},
+			want: []int{-1, -1, -1, -1},
+		},
+		{
+			name: "nothing open",
+			want: []int{-1, -1, -1, -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchWindows(saved, tt.open)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
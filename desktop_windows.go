@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32"
+)
+
+// windowsDesktops uses the documented IVirtualDesktopManager for windows and
+// the registry, where Explorer keeps the list of desktops, their names and
+// the current one. There is no documented API for these.
+type windowsDesktops struct {
+	manager *virtualDesktopManager
+}
+
+type virtualDesktopManager struct {
+	vtbl *virtualDesktopManagerVtbl
+}
+
+type virtualDesktopManagerVtbl struct {
+	QueryInterface                  uintptr
+	AddRef                          uintptr
+	Release                         uintptr
+	IsWindowOnCurrentVirtualDesktop uintptr
+	GetWindowDesktopId              uintptr
+	MoveWindowToDesktop             uintptr
+}
+
+var (
+	clsidVirtualDesktopManager = w32.GUID{
+		Data1: 0xAA509086, Data2: 0x5CA9, Data3: 0x4C25,
+		Data4: [8]byte{0x8F, 0x95, 0x58, 0x9D, 0x3C, 0x07, 0xB4, 0x8A},
+	}
+	iidVirtualDesktopManager = w32.GUID{
+		Data1: 0xA5CD92FF, Data2: 0x29BE, Data3: 0x454C,
+		Data4: [8]byte{0x8D, 0x04, 0xD8, 0x28, 0x79, 0xFB, 0x3F, 0x1B},
+	}
+)
+
+const virtualDesktopsKey = `Software\Microsoft\Windows\CurrentVersion\Explorer\VirtualDesktops`
+
+// theDesktops is created on first use, on the thread that uses it. COM objects
+// must only be used on the thread that created them, all callers run on the
+// locked main thread.
+var theDesktops virtualDesktops
+
+// desktops returns the virtual desktops. Before Windows 10 this is a single
+// desktop.
+func desktops() virtualDesktops {
+	if theDesktops == nil {
+		theDesktops = newDesktops()
+	}
+	return theDesktops
+}
+
+func newDesktops() virtualDesktops {
+	w32.CoInitializeEx(w32.COINIT_APARTMENTTHREADED)
+	var m *virtualDesktopManager
+	ret, _, _ := coCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidVirtualDesktopManager)),
+		0,
+		clsctxAll,
+		uintptr(unsafe.Pointer(&iidVirtualDesktopManager)),
+		uintptr(unsafe.Pointer(&m)),
+	)
+	if ret != 0 || m == nil {
+		log.debugf("no virtual desktops, CoCreateInstance failed with 0x%X", ret)
+		return singleDesktop{}
+	}
+	return &windowsDesktops{manager: m}
+}
+
+func (d *windowsDesktops) current() string {
+	paths := []string{virtualDesktopsKey}
+	var session uint32
+	if ret, _, _ := processIdToSessionId.Call(
+		uintptr(w32.GetCurrentProcessId()),
+		uintptr(unsafe.Pointer(&session)),
+	); ret != 0 {
+		// Windows 10 keeps the current desktop per session.
+		paths = append(paths,
+			`Software\Microsoft\Windows\CurrentVersion\Explorer\SessionInfo\`+
+				strconv.Itoa(int(session))+`\VirtualDesktops`,
+		)
+	}
+	for _, path := range paths {
+		data := w32.RegGetRaw(w32.HKEY_CURRENT_USER, path, "CurrentVirtualDesktop")
+		if len(data) == 16 {
+			return guidString(guidFromBytes(data))
+		}
+	}
+	// Explorer did not write the registry yet, the desktop of any window
+	// that shows is the current one.
+	for _, window := range enumWindows() {
+		if w32.IsWindowVisible(window) && d.onCurrent(uintptr(window)) {
+			if desktop := d.windowDesktop(uintptr(window)); desktop != "" {
+				return desktop
+			}
+		}
+	}
+	return ""
+}
+
+func (d *windowsDesktops) all() []string {
+	data := w32.RegGetRaw(w32.HKEY_CURRENT_USER, virtualDesktopsKey, "VirtualDesktopIDs")
+	var all []string
+	for len(data) >= 16 {
+		all = append(all, guidString(guidFromBytes(data[:16])))
+		data = data[16:]
+	}
+	if len(all) == 0 {
+		// The registry lists the desktops only after the user created a
+		// second one.
+		all = []string{d.current()}
+	}
+	return all
+}
+
+func (d *windowsDesktops) name(desktop string) string {
+	custom := w32.RegGetString(
+		w32.HKEY_CURRENT_USER,
+		virtualDesktopsKey+`\Desktops\`+desktop,
+		"Name",
+	)
+	return desktopName(d.all(), desktop, custom)
+}
+
+func (d *windowsDesktops) onCurrent(window uintptr) bool {
+	var onCurrent int32
+	ret, _, _ := syscall.SyscallN(
+		d.manager.vtbl.IsWindowOnCurrentVirtualDesktop,
+		uintptr(unsafe.Pointer(d.manager)),
+		window,
+		uintptr(unsafe.Pointer(&onCurrent)),
+	)
+	// Windows that the manager does not know about, e.g. because they
+	// belong to the shell, show everywhere.
+	return ret != 0 || onCurrent != 0
+}
+
+func (d *windowsDesktops) windowDesktop(window uintptr) string {
+	var id w32.GUID
+	ret, _, _ := syscall.SyscallN(
+		d.manager.vtbl.GetWindowDesktopId,
+		uintptr(unsafe.Pointer(d.manager)),
+		window,
+		uintptr(unsafe.Pointer(&id)),
+	)
+	if ret != 0 || id == (w32.GUID{}) {
+		return ""
+	}
+	return guidString(id)
+}
+
+// guidFromBytes reads a GUID in its binary form from the registry.
+func guidFromBytes(b []byte) w32.GUID {
+	g := w32.GUID{
+		Data1: binary.LittleEndian.Uint32(b[0:]),
+		Data2: binary.LittleEndian.Uint16(b[4:]),
+		Data3: binary.LittleEndian.Uint16(b[6:]),
+	}
+	copy(g.Data4[:], b[8:16])
+	return g
+}
+
+// guidString formats the GUID like the registry keys of the desktops, e.g.
+// {3F1C0A5E-6D4B-4C1A-9E0F-2B7A1D5C8E90}.
+func guidString(g w32.GUID) string {
+	return fmt.Sprintf(
+		"{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		g.Data1, g.Data2, g.Data3,
+		g.Data4[0], g.Data4[1], g.Data4[2], g.Data4[3],
+		g.Data4[4], g.Data4[5], g.Data4[6], g.Data4[7],
+	)
+}
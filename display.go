@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gonutz/tile_screen/bsp"
+)
+
+// display is a monitor as far as placing windows is concerned.
+type display struct {
+	// id is the monitor's monitorIdentity.id, it stays the same when the
+	// device names change.
+	id     string
+	bounds rect
+	work   rect
+}
+
+// tilePlacement is where a window was put: tiles of a cols by rows grid on
+// the monitor with the given id, or a leaf of a split layout.
+type tilePlacement struct {
+	monitor    string
+	tiles      rect
+	cols, rows int
+	// tree is the split layout if the window went into one of its leaves,
+	// leaf is the index into its Leaves then. Split layouts adapt to any
+	// monitor so the leaf stays the same when the monitors change.
+	tree *bsp.Node
+	leaf int
+}
+
+func (p tilePlacement) String() string {
+	if p.tree != nil {
+		return fmt.Sprintf("region %d of the split layout on %s", p.leaf+1, p.monitor)
+	}
+	return fmt.Sprintf("tiles %v of the %dx%d grid on %s", p.tiles, p.cols, p.rows, p.monitor)
+}
+
+// displayChange is the difference between two monitor configurations, as
+// monitor ids. changed monitors are still there but have other bounds or
+// another work area, e.g. after changing the resolution.
+type displayChange struct {
+	removed, added, changed []string
+}
+
+func (c displayChange) empty() bool {
+	return len(c.removed) == 0 && len(c.added) == 0 && len(c.changed) == 0
+}
+
+// affects reports whether windows on the monitor need to be placed again.
+func (c displayChange) affects(id string) bool {
+	for _, d := range c.removed {
+		if d == id {
+			return true
+		}
+	}
+	for _, d := range c.changed {
+		if d == id {
+			return true
+		}
+	}
+	return false
+}
+
+// diffDisplays compares the monitors before and after a change, the results
+// are in the order of the given lists.
+func diffDisplays(before, after []display) displayChange {
+	var c displayChange
+	for _, b := range before {
+		a, ok := findDisplay(after, b.id)
+		if !ok {
+			c.removed = append(c.removed, b.id)
+		} else if a.bounds != b.bounds || a.work != b.work {
+			c.changed = append(c.changed, b.id)
+		}
+	}
+	for _, a := range after {
+		if _, ok := findDisplay(before, a.id); !ok {
+			c.added = append(c.added, a.id)
+		}
+	}
+	return c
+}
+
+func findDisplay(displays []display, id string) (display, bool) {
+	for _, d := range displays {
+		if d.id == id {
+			return d, true
+		}
+	}
+	return display{}, false
+}
+
+// fallbackDisplay returns the index of the monitor in displays that takes the
+// windows of a monitor that is gone. This is the monitor that now covers the
+// center of the old one, or else the one closest to it. It returns -1 if
+// there are no displays.
+func fallbackDisplay(gone display, displays []display) int {
+	cx := (gone.bounds.left + gone.bounds.right) / 2
+	cy := (gone.bounds.top + gone.bounds.bottom) / 2
+	best, bestDist := -1, 0
+	for i, d := range displays {
+		// The distance from the center to the closest point of the monitor,
+		// 0 if it contains the center.
+		dx := max(0, max(d.bounds.left-cx, cx-(d.bounds.right-1)))
+		dy := max(0, max(d.bounds.top-cy, cy-(d.bounds.bottom-1)))
+		dist := dx*dx + dy*dy
+		if best == -1 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// remapPlacement returns where a window placed at p goes after the monitors
+// changed from before to after. It keeps the same tiles on the same monitor,
+// or the same relative tiles on the fallback monitor if its monitor is gone.
+// gridFor returns the grid of a monitor. It returns false if the window does
+// not have to move or there is no monitor left.
+func remapPlacement(
+	p tilePlacement,
+	before, after []display,
+	change displayChange,
+	gridFor func(id string) (cols, rows int),
+) (tilePlacement, bool) {
+	if !change.affects(p.monitor) {
+		return p, false
+	}
+	if _, ok := findDisplay(after, p.monitor); ok {
+		return p, true
+	}
+	gone, ok := findDisplay(before, p.monitor)
+	if !ok {
+		return p, false
+	}
+	i := fallbackDisplay(gone, after)
+	if i == -1 {
+		return p, false
+	}
+	if p.tree != nil {
+		p.monitor = after[i].id
+		return p, true
+	}
+	cols, rows := gridFor(after[i].id)
+	return tilePlacement{
+		monitor: after[i].id,
+		tiles:   rescaleTiles(p.tiles, p.cols, p.rows, cols, rows),
+		cols:    cols,
+		rows:    rows,
+	}, true
+}
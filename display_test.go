@@ -0,0 +1,212 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gonutz/tile_screen/bsp"
+)
+
+var (
+	// The laptop screen on the left and a wider monitor on its right.
+	laptop = display{
+		id:     "LEN40A0/UID0",
+		bounds: rect{0, 0, 1920, 1080},
+		work:   rect{0, 0, 1920, 1040},
+	}
+	external = display{
+		id:     "DEL4109/UID4353",
+		bounds: rect{1920, 0, 4480, 1440},
+		work:   rect{1920, 0, 4480, 1400},
+	}
+)
+
+func TestDiffDisplays(t *testing.T) {
+	taskbarOnTop := laptop
+	taskbarOnTop.work = rect{0, 40, 1920, 1080}
+	moved := external
+	moved.bounds = rect{-2560, 0, 0, 1440}
+	moved.work = rect{-2560, 0, 0, 1400}
+	tests := []struct {
+		name          string
+		before, after []display
+		want          displayChange
+	}{
+		{
+			name:   "nothing changed",
+			before: []display{laptop, external},
+			after:  []display{external, laptop},
+			want:   displayChange{},
+		},
+		{
+			name:   "monitor unplugged",
+			before: []display{laptop, external},
+			after:  []display{laptop},
+			want:   displayChange{removed: []string{external.id}},
+		},
+		{
+			name:   "monitor plugged in",
+			before: []display{laptop},
+			after:  []display{laptop, external},
+			want:   displayChange{added: []string{external.id}},
+		},
+		{
+			name:   "work area changed",
+			before: []display{laptop, external},
+			after:  []display{taskbarOnTop, external},
+			want:   displayChange{changed: []string{laptop.id}},
+		},
+		{
+			name:   "monitor moved to the other side",
+			before: []display{laptop, external},
+			after:  []display{laptop, moved},
+			want:   displayChange{changed: []string{external.id}},
+		},
+		{
+			name:   "monitor swapped",
+			before: []display{laptop},
+			after:  []display{external},
+			want:   displayChange{removed: []string{laptop.id}, added: []string{external.id}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffDisplays(tt.before, tt.after)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.empty() != reflect.DeepEqual(tt.want, displayChange{}) {
+				t.Errorf("empty is %v", got.empty())
+			}
+		})
+	}
+}
+
+func TestFallbackDisplay(t *testing.T) {
+	above := display{id: "above", bounds: rect{0, -1080, 1920, 0}}
+	mirror := display{id: "mirror", bounds: rect{1920, 0, 3840, 1080}}
+	tests := []struct {
+		name     string
+		gone     display
+		displays []display
+		want     int
+	}{
+		{
+			name:     "closest to the left",
+			gone:     external,
+			displays: []display{above, laptop},
+			want:     1,
+		},
+		{
+			name:     "covers the center",
+			gone:     external,
+			displays: []display{laptop, mirror},
+			want:     1,
+		},
+		{
+			name:     "closest above",
+			gone:     laptop,
+			displays: []display{external, above},
+			want:     1,
+		},
+		{
+			name: "the first of equally close ones",
+			gone: display{bounds: rect{1000, 0, 2000, 1000}},
+			displays: []display{
+				{id: "left", bounds: rect{0, 0, 1001, 1000}},
+				{id: "right", bounds: rect{2000, 0, 3000, 1000}},
+			},
+			want: 0,
+		},
+		{
+			name: "no monitors left",
+			gone: laptop,
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fallbackDisplay(tt.gone, tt.displays)
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemapPlacement(t *testing.T) {
+	grids := map[string][2]int{laptop.id: {3, 3}, external.id: {2, 2}}
+	gridFor := func(id string) (int, int) {
+		return grids[id][0], grids[id][1]
+	}
+	tree := bsp.NewLeaf()
+	tree.SplitLeaf(bsp.LeftRight, 0.3)
+	taskbarOnTop := laptop
+	taskbarOnTop.work = rect{0, 40, 1920, 1080}
+
+	rightHalf := tilePlacement{monitor: external.id, tiles: rect{1, 0, 2, 2}, cols: 2, rows: 2}
+	onLaptop := tilePlacement{monitor: laptop.id, tiles: rect{0, 0, 1, 3}, cols: 3, rows: 3}
+	split := tilePlacement{monitor: external.id, tree: tree, leaf: 1}
+	tests := []struct {
+		name          string
+		p             tilePlacement
+		before, after []display
+		want          tilePlacement
+		wantOK        bool
+	}{
+		{
+			name:   "unplugged monitor rescales to the fallback",
+			p:      rightHalf,
+			before: []display{laptop, external},
+			after:  []display{laptop},
+			want:   tilePlacement{monitor: laptop.id, tiles: rect{1, 0, 3, 3}, cols: 3, rows: 3},
+			wantOK: true,
+		},
+		{
+			name:   "unplugged monitor keeps the split region",
+			p:      split,
+			before: []display{laptop, external},
+			after:  []display{laptop},
+			want:   tilePlacement{monitor: laptop.id, tree: tree, leaf: 1},
+			wantOK: true,
+		},
+		{
+			name:   "other monitor unplugged",
+			p:      onLaptop,
+			before: []display{laptop, external},
+			after:  []display{laptop},
+			want:   onLaptop,
+		},
+		{
+			name:   "work area changed keeps the tiles",
+			p:      onLaptop,
+			before: []display{laptop, external},
+			after:  []display{taskbarOnTop, external},
+			want:   onLaptop,
+			wantOK: true,
+		},
+		{
+			name:   "monitor plugged in",
+			p:      onLaptop,
+			before: []display{laptop},
+			after:  []display{laptop, external},
+			want:   onLaptop,
+		},
+		{
+			name:   "all monitors unplugged",
+			p:      onLaptop,
+			before: []display{laptop},
+			after:  nil,
+			want:   onLaptop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := diffDisplays(tt.before, tt.after)
+			got, ok := remapPlacement(tt.p, tt.before, tt.after, change, gridFor)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v %v, want %v %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
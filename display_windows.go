@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gonutz/tile_screen/bsp"
+	"github.com/gonutz/w32"
+)
+
+// placementListener is told about every window that the overlay or a tile
+// command puts into grid tiles or a split layout. Resident mode uses it to
+// remember where its windows go, otherwise it is nil.
+var placementListener func(window w32.HWND, p tilePlacement)
+
+func notePlacement(window w32.HWND, monitor w32.HMONITOR, tiles rect, cols, rows int) {
+	if placementListener != nil {
+		placementListener(window, tilePlacement{
+			monitor: identifyMonitor(monitor).id(),
+			tiles:   tiles,
+			cols:    cols,
+			rows:    rows,
+		})
+	}
+}
+
+// noteLeafPlacement is notePlacement for a window put into a leaf of a split
+// layout, leaf is its index in tree.Leaves.
+func noteLeafPlacement(window w32.HWND, monitor w32.HMONITOR, tree *bsp.Node, leaf int) {
+	if placementListener != nil {
+		placementListener(window, tilePlacement{
+			monitor: identifyMonitor(monitor).id(),
+			tree:    tree.Copy(),
+			leaf:    leaf,
+		})
+	}
+}
+
+// currentDisplays returns all monitors along with their handles, in the same
+// order.
+func currentDisplays() ([]display, []w32.HMONITOR) {
+	var displays []display
+	var handles []w32.HMONITOR
+	for _, m := range enumMonitors() {
+		var info w32.MONITORINFO
+		if !w32.GetMonitorInfo(m, &info) {
+			continue
+		}
+		displays = append(displays, display{
+			id:     identifyMonitor(m).id(),
+			bounds: fromRECT(info.RcMonitor),
+			work:   fromRECT(info.RcWork),
+		})
+		handles = append(handles, m)
+	}
+	return displays, handles
+}
+
+// identifyMonitor returns what identifies the monitor in the config.
+func identifyMonitor(monitor w32.HMONITOR) monitorIdentity {
+	m := monitorIdentity{device: monitorDevice(monitor)}
+	var info w32.MONITORINFO
+	if w32.GetMonitorInfo(monitor, &info) {
+		m.bounds = fromRECT(info.RcMonitor)
+	}
+	path, _ := monitorDeviceID(m.device)
+	m.model, m.instance = parseDeviceID(path)
+	return m
+}
+
+// identifyMonitors returns the identities of all monitors, in the same order
+// as enumMonitors.
+func identifyMonitors() []monitorIdentity {
+	var all []monitorIdentity
+	for _, m := range enumMonitors() {
+		all = append(all, identifyMonitor(m))
+	}
+	return all
+}
+
+// gridOfMonitor returns the grid of the monitor from the config.
+func gridOfMonitor(cfg config, monitor w32.HMONITOR) (cols, rows int) {
+	return cfg.monitorGrid(identifyMonitor(monitor), identifyMonitors())
+}
+
+// listMonitorsCommand prints all monitors with their ids for the config and
+// the grids that the config gives them.
+func listMonitorsCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("list-monitors", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	defer usePhysicalPixels()()
+
+	out := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "ID\tDEVICE\tBOUNDS\tDPI\tGRID\tNAME")
+	for _, m := range describeMonitors(cfg) {
+		name := m.Name
+		if m.Primary {
+			name += " (primary)"
+		}
+		fmt.Fprintf(
+			out, "%s\t%s\t%v\t%d\t%s\t%s\n",
+			m.ID, m.Device, m.Bounds.rect(), m.DPI, m.Grid, name,
+		)
+	}
+	return out.Flush()
+}
+
+// describeMonitors returns everything about the monitors that list-monitors
+// shows, in the same order as enumMonitors.
+func describeMonitors(cfg config) []ipcMonitor {
+	handles := enumMonitors()
+	var all []monitorIdentity
+	for _, handle := range handles {
+		all = append(all, identifyMonitor(handle))
+	}
+	monitors := []ipcMonitor{}
+	for i, handle := range handles {
+		m := all[i]
+		_, name := monitorDeviceID(m.device)
+		var info w32.MONITORINFO
+		w32.GetMonitorInfo(handle, &info)
+		cols, rows := cfg.monitorGrid(m, all)
+		monitors = append(monitors, ipcMonitor{
+			ID:      m.id(),
+			Device:  m.device,
+			Name:    name,
+			Primary: info.DwFlags&w32.MONITORINFOF_PRIMARY != 0,
+			Bounds:  toIPCRect(m.bounds),
+			Work:    toIPCRect(fromRECT(info.RcWork)),
+			DPI:     monitorDPI(handle),
+			Grid:    fmt.Sprintf("%dx%d", cols, rows),
+		})
+	}
+	return monitors
+}
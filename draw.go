@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gonutz/tile_screen/bsp"
+)
+
+// canvas is what the overlay is drawn on. The GDI canvas draws the actual
+// overlay window, the image canvas draws into an image.RGBA so the overlay can
+// be rendered without Windows, e.g. to generate previews.
+type canvas interface {
+	// fillRoundRect fills r with rounded corners, blending by the color's
+	// alpha.
+	fillRoundRect(r rect, radius int, c color)
+	// frameRoundRect draws the outline of r with rounded corners and the
+	// given line width, inside r.
+	frameRoundRect(r rect, width, radius int, c color)
+	// text draws a single line of text into r in the theme's font and text
+	// color.
+	text(s string, r rect, t theme, size int, align textAlign)
+	// textSize returns the size that text would have.
+	textSize(s string, t theme, size int) (width, height int)
+}
+
+type textAlign int
+
+const (
+	alignTopLeft textAlign = iota
+	alignCenter
+)
+
+// overlayView is everything that is visible in the overlay.
+type overlayView struct {
+	width, height int
+	cols, rows    int
+	// selection is in pixels, it is noSelection if nothing is selected.
+	selection  rect
+	preview    placement
+	hasPreview bool
+	// feedback is a message for the user, shown in the center.
+	feedback string
+	// windows is shown on top of the grid if it is visible.
+	windows windowList
+	// tree replaces the grid if it is not nil, selectedLeaf is highlighted.
+	tree         *bsp.Node
+	selectedLeaf *bsp.Node
+	theme        theme
+}
+
+func drawOverlay(c canvas, v overlayView) {
+	t := v.theme
+	full := rect{right: v.width, bottom: v.height}
+	if v.tree != nil {
+		drawTree(c, v.tree, v.selectedLeaf, full, t)
+	} else {
+		drawGrid(c, v)
+	}
+	if v.hasPreview {
+		c.frameRoundRect(v.preview.rect, t.PreviewWidth, t.CornerRadius, t.Preview)
+		drawReadout(
+			c,
+			readoutText(v.preview, v.cols, v.rows),
+			readoutBounds(v.width, v.height, t.FontSize),
+			t,
+		)
+	}
+	if v.windows.visible {
+		drawWindowList(c, v.windows, v.width, v.height, t)
+	}
+	if v.feedback != "" {
+		drawReadout(c, v.feedback, full, t)
+	}
+}
+
+// drawWindowList draws the windows that can be tiled at once, highlighting
+// the selected ones.
+func drawWindowList(c canvas, l windowList, width, height int, t theme) {
+	list := windowListBounds(width, height, len(l.entries), t.FontSize)
+	c.fillRoundRect(list, t.CornerRadius, t.Background)
+	c.frameRoundRect(list, t.BorderWidth, t.CornerRadius, t.Grid)
+	for i, e := range l.entries {
+		r := windowEntryBounds(list, i, t.FontSize)
+		if r.bottom > list.bottom {
+			break
+		}
+		if e.selected {
+			c.fillRoundRect(r.inset(2), t.CornerRadius, t.Selection)
+		}
+		textR := rect{
+			left:   r.left + t.FontSize/2,
+			top:    r.top + t.FontSize/4,
+			right:  r.right - t.FontSize/2,
+			bottom: r.bottom,
+		}
+		c.text(windowEntryText(e), textR, t, t.FontSize*3/4, alignTopLeft)
+	}
+}
+
+func drawGrid(c canvas, v overlayView) {
+	t := v.theme
+	for x := 0; x < v.cols; x++ {
+		for y := 0; y < v.rows; y++ {
+			tile := tileBounds(x, y, v.width, v.height, v.cols, v.rows)
+			selected := touches(tile, v.selection)
+			if selected {
+				c.fillRoundRect(tile, t.CornerRadius, t.Selection)
+			}
+			if selected && t.SelectionOutlineWidth > 0 {
+				c.frameRoundRect(tile, t.SelectionOutlineWidth, t.CornerRadius, t.SelectionOutline)
+			} else {
+				c.frameRoundRect(tile, t.BorderWidth, t.CornerRadius, t.Grid)
+			}
+			c.text(
+				tileLabel(x, y),
+				labelBounds(tile, t.FontSize),
+				t, t.FontSize*3/4, alignTopLeft,
+			)
+		}
+	}
+}
+
+// drawReadout draws the text centered in r, on a background so it stays
+// readable on top of the tiles.
+func drawReadout(c canvas, text string, r rect, t theme) {
+	textW, textH := c.textSize(text, t, t.FontSize)
+	padding := t.FontSize / 2
+	w, h := textW+2*padding, textH+padding
+	x := r.left + (r.width()-w)/2
+	y := r.top + (r.height()-h)/2
+	c.fillRoundRect(rect{x, y, x + w, y + h}, t.CornerRadius, t.Background)
+	c.text(text, r, t, t.FontSize, alignCenter)
+}
+
+// drawTree draws the leaves of a split layout like tiles, numbered from the
+// top left to the bottom right.
+func drawTree(c canvas, tree, selected *bsp.Node, bounds rect, t theme) {
+	for i, leaf := range tree.Leaves(toBSP(bounds)) {
+		r := fromBSP(leaf.Bounds)
+		r = rect{left: r.left + 2, top: r.top + 2, right: r.right - 4, bottom: r.bottom - 4}
+		if leaf.Node == selected {
+			c.fillRoundRect(r, t.CornerRadius, t.Selection)
+			c.frameRoundRect(r, max(t.SelectionOutlineWidth, t.BorderWidth), t.CornerRadius, t.SelectionOutline)
+		} else {
+			c.frameRoundRect(r, t.BorderWidth, t.CornerRadius, t.Grid)
+		}
+		c.text(strconv.Itoa(i+1), labelBounds(r, t.FontSize), t, t.FontSize*3/4, alignTopLeft)
+	}
+}
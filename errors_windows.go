@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/gonutz/w32"
+)
+
+// win32Error is a failed Win32 call along with the code from GetLastError.
+// It unwraps to the syscall.Errno so errors.Is works with the known codes.
+type win32Error struct {
+	call string
+	code syscall.Errno
+}
+
+// lastError must be called right after the failed function, before any other
+// Win32 call overwrites the last error.
+func lastError(call string) error {
+	return win32Error{call: call, code: syscall.Errno(w32.GetLastError())}
+}
+
+func (e win32Error) Error() string {
+	return fmt.Sprintf("%s failed: %v (error code %d)", e.call, e.code, uint32(e.code))
+}
+
+func (e win32Error) Unwrap() error {
+	return e.code
+}
+
+// fatal logs the error, tells the user about it and exits. Since the program
+// is built without a console, a message box is the only way for users to see
+// what went wrong.
+func fatal(err error) {
+	log.errorf("%v", err)
+	w32.MessageBox(
+		0,
+		err.Error()+"\n\nSee the log file for details:\n"+log.path,
+		"tile_screen",
+		w32.MB_OK|w32.MB_ICONERROR|w32.MB_TOPMOST,
+	)
+	os.Exit(1)
+}
+
+// reportPanics is deferred at the start of main. It turns panics into fatal
+// errors, with the stack trace going to the log file.
+func reportPanics() {
+	if err := recover(); err != nil {
+		log.errorf("panic: %v\n%s", err, debug.Stack())
+		fatal(fmt.Errorf("the program crashed: %v", err))
+	}
+}
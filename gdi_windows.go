@@ -0,0 +1,257 @@
+package main
+
+import (
+	"image"
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32"
+)
+
+// backBuffer is the 32-bit bitmap that the overlay is drawn into. It is shown
+// with UpdateLayeredWindow, which uses the alpha of every pixel, so only what
+// is drawn covers the windows below and the rest of the overlay stays
+// see-through.
+type backBuffer struct {
+	dc        w32.HDC
+	bitmap    w32.HBITMAP
+	oldBitmap w32.HGDIOBJ
+	// pixels are the bitmap's premultiplied BGRA pixels, top row first.
+	pixels        []byte
+	img           *image.RGBA
+	width, height int
+}
+
+// begin returns a canvas of the given size, cleared to transparent. The
+// bitmap is kept between paints and only re-created if the size changes.
+func (b *backBuffer) begin(width, height int) layeredCanvas {
+	if b.dc == 0 || b.width != width || b.height != height {
+		b.free()
+		info := w32.BITMAPINFO{BmiHeader: w32.BITMAPINFOHEADER{
+			BiWidth:       int32(width),
+			BiHeight:      -int32(height),
+			BiPlanes:      1,
+			BiBitCount:    32,
+			BiCompression: w32.BI_RGB,
+		}}
+		info.BmiHeader.BiSize = uint32(unsafe.Sizeof(info.BmiHeader))
+		var bits unsafe.Pointer
+		b.dc = w32.CreateCompatibleDC(0)
+		b.bitmap = w32.CreateDIBSection(b.dc, &info, w32.DIB_RGB_COLORS, &bits, 0, 0)
+		b.oldBitmap = w32.SelectObject(b.dc, w32.HGDIOBJ(b.bitmap))
+		b.pixels = unsafe.Slice((*byte)(bits), 4*width*height)
+		b.img = image.NewRGBA(image.Rect(0, 0, width, height))
+		b.width, b.height = width, height
+	}
+	clear(b.img.Pix)
+	return layeredCanvas{imageCanvas: imageCanvas{img: b.img}, dc: b.dc, pixels: b.pixels}
+}
+
+// end shows the frame in the layered window, faded by alpha as a whole.
+func (b *backBuffer) end(window w32.HWND, alpha byte) error {
+	a := int(alpha)
+	for i := 0; i < len(b.img.Pix); i += 4 {
+		src := b.img.Pix[i : i+4 : i+4]
+		b.pixels[i+0] = byte(int(src[2]) * a / 255)
+		b.pixels[i+1] = byte(int(src[1]) * a / 255)
+		b.pixels[i+2] = byte(int(src[0]) * a / 255)
+		// Pixels with alpha 0 let the mouse through to the windows below,
+		// but the overlay needs it everywhere to select tiles.
+		b.pixels[i+3] = byte(max(1, int(src[3])*a/255))
+	}
+	size := w32.SIZE{CX: int32(b.width), CY: int32(b.height)}
+	var origin w32.POINT
+	blend := w32.BLENDFUNC{BlendOp: w32.AC_SRC_OVER, SourceConstantAlpha: 255, AlphaFormat: acSrcAlpha}
+	ret, _, _ := updateLayeredWindow.Call(
+		uintptr(window), 0, 0,
+		uintptr(unsafe.Pointer(&size)),
+		uintptr(b.dc),
+		uintptr(unsafe.Pointer(&origin)),
+		0,
+		uintptr(unsafe.Pointer(&blend)),
+		ulwAlpha,
+	)
+	if ret == 0 {
+		return lastError("UpdateLayeredWindow")
+	}
+	return nil
+}
+
+func (b *backBuffer) free() {
+	if b.dc == 0 {
+		return
+	}
+	w32.SelectObject(b.dc, b.oldBitmap)
+	w32.DeleteObject(w32.HGDIOBJ(b.bitmap))
+	w32.DeleteDC(b.dc)
+	*b = backBuffer{}
+}
+
+// layeredCanvas draws the shapes like the image canvas, with alpha. GDI cannot
+// draw text with alpha, so text is drawn white on black into the back
+// buffer's bitmap first and then used as the coverage of the text color.
+type layeredCanvas struct {
+	imageCanvas
+	dc     w32.HDC
+	pixels []byte
+}
+
+func (c layeredCanvas) text(s string, r rect, t theme, size int, align textAlign) {
+	bounds := c.img.Rect
+	clipped := rect{
+		left:   max(r.left, bounds.Min.X),
+		top:    max(r.top, bounds.Min.Y),
+		right:  min(r.right, bounds.Max.X),
+		bottom: min(r.bottom, bounds.Max.Y),
+	}
+	if clipped.width() <= 0 || clipped.height() <= 0 {
+		return
+	}
+	w32.PatBlt(c.dc, clipped.left, clipped.top, clipped.width(), clipped.height(), w32.BLACKNESS)
+	mask := t
+	mask.Text = rgba(0xff, 0xff, 0xff, 0xff)
+	gdiCanvas{dc: c.dc}.text(s, r, mask, size, align)
+	gdiFlush.Call()
+
+	stride := 4 * bounds.Dx()
+	for y := clipped.top; y < clipped.bottom; y++ {
+		for x := clipped.left; x < clipped.right; x++ {
+			i := y*stride + 4*x
+			coverage := (uint32(c.pixels[i]) + uint32(c.pixels[i+1]) + uint32(c.pixels[i+2])) / 3
+			if coverage > 0 {
+				col := t.Text
+				col.a = uint8(coverage * uint32(t.Text.a) / 255)
+				c.blend(x, y, col)
+			}
+		}
+	}
+}
+
+func (c layeredCanvas) textSize(s string, t theme, size int) (width, height int) {
+	return gdiCanvas{dc: c.dc}.textSize(s, t, size)
+}
+
+func colorRef(c color) uint32 {
+	return uint32(c.r) | uint32(c.g)<<8 | uint32(c.b)<<16
+}
+
+// gdiCanvas draws on a GDI device context.
+type gdiCanvas struct {
+	dc w32.HDC
+}
+
+func roundRectRgn(r rect, radius int) w32.HRGN {
+	var rgn uintptr
+	if radius <= 0 {
+		rgn, _, _ = createRectRgn.Call(
+			uintptr(r.left), uintptr(r.top), uintptr(r.right), uintptr(r.bottom),
+		)
+	} else {
+		// The right and bottom of round rect regions are exclusive minus one.
+		rgn, _, _ = createRoundRectRgn.Call(
+			uintptr(r.left), uintptr(r.top), uintptr(r.right+1), uintptr(r.bottom+1),
+			uintptr(2*radius), uintptr(2*radius),
+		)
+	}
+	return w32.HRGN(rgn)
+}
+
+func (c gdiCanvas) fillRoundRect(r rect, radius int, col color) {
+	rgn := roundRectRgn(r, radius)
+	c.fillRegion(rgn, r, col)
+	w32.DeleteObject(w32.HGDIOBJ(rgn))
+}
+
+func (c gdiCanvas) frameRoundRect(r rect, width, radius int, col color) {
+	if width <= 0 {
+		return
+	}
+	outer := roundRectRgn(r, radius)
+	inner := roundRectRgn(r.inset(width), radius-width)
+	combineRgn.Call(uintptr(outer), uintptr(outer), uintptr(inner), rgnDiff)
+	c.fillRegion(outer, r, col)
+	w32.DeleteObject(w32.HGDIOBJ(inner))
+	w32.DeleteObject(w32.HGDIOBJ(outer))
+}
+
+// fillRegion fills the part of bounds that is inside the region.
+func (c gdiCanvas) fillRegion(rgn w32.HRGN, bounds rect, col color) {
+	if col.a == 0 {
+		return
+	}
+	selectClipRgn.Call(uintptr(c.dc), uintptr(rgn))
+	defer selectClipRgn.Call(uintptr(c.dc), 0)
+
+	brush := w32.CreateSolidBrush(colorRef(col))
+	defer w32.DeleteObject(w32.HGDIOBJ(brush))
+	if col.a == 255 {
+		r := toRECT(bounds)
+		w32.FillRect(c.dc, &r, brush)
+		return
+	}
+
+	// GDI cannot fill with alpha, so a single pixel of the color is stretched
+	// over the bounds with AlphaBlend.
+	src := w32.CreateCompatibleDC(c.dc)
+	defer w32.DeleteDC(src)
+	pixel := w32.CreateCompatibleBitmap(c.dc, 1, 1)
+	defer w32.DeleteObject(w32.HGDIOBJ(pixel))
+	old := w32.SelectObject(src, w32.HGDIOBJ(pixel))
+	defer w32.SelectObject(src, old)
+	w32.FillRect(src, &w32.RECT{Right: 1, Bottom: 1}, brush)
+	w32.AlphaBlend(
+		c.dc, bounds.left, bounds.top, bounds.width(), bounds.height(),
+		src, 0, 0, 1, 1,
+		w32.BLENDFUNC{BlendOp: w32.AC_SRC_OVER, SourceConstantAlpha: col.a},
+	)
+}
+
+func (c gdiCanvas) text(s string, r rect, t theme, size int, align textAlign) {
+	font := selectFont(c.dc, t, size)
+	defer font.restore()
+
+	flags := uint(w32.DT_SINGLELINE | w32.DT_NOPREFIX)
+	if align == alignCenter {
+		flags |= w32.DT_CENTER | w32.DT_VCENTER
+	}
+	w32.SetBkMode(c.dc, w32.TRANSPARENT)
+	w32.SetTextColor(c.dc, w32.COLORREF(colorRef(t.Text)))
+	gdiRect := toRECT(r)
+	w32.DrawText(c.dc, s, &gdiRect, flags)
+}
+
+func (c gdiCanvas) textSize(s string, t theme, size int) (width, height int) {
+	font := selectFont(c.dc, t, size)
+	defer font.restore()
+
+	var r w32.RECT
+	w32.DrawText(c.dc, s, &r, w32.DT_CALCRECT|w32.DT_SINGLELINE|w32.DT_NOPREFIX)
+	return int(r.Width()), int(r.Height())
+}
+
+type selectedFont struct {
+	dc   w32.HDC
+	font w32.HFONT
+	old  w32.HGDIOBJ
+}
+
+func selectFont(dc w32.HDC, t theme, size int) selectedFont {
+	logFont := w32.LOGFONT{
+		Height:  int32(-size),
+		Weight:  w32.FW_NORMAL,
+		CharSet: w32.DEFAULT_CHARSET,
+		Quality: w32.CLEARTYPE_QUALITY,
+	}
+	copy(logFont.FaceName[:len(logFont.FaceName)-1], syscall.StringToUTF16(t.Font))
+	font := w32.CreateFontIndirect(&logFont)
+	return selectedFont{
+		dc:   dc,
+		font: font,
+		old:  w32.SelectObject(dc, w32.HGDIOBJ(font)),
+	}
+}
+
+func (f selectedFont) restore() {
+	w32.SelectObject(f.dc, f.old)
+	w32.DeleteObject(w32.HGDIOBJ(f.font))
+}
@@ -0,0 +1,278 @@
+package main
+
+import "fmt"
+
+// rect is an axis-aligned rectangle. Right and bottom are exclusive. Depending
+// on context it is measured in pixels or in tiles.
+type rect struct {
+	left, top, right, bottom int
+}
+
+func (r rect) width() int {
+	return r.right - r.left
+}
+
+func (r rect) height() int {
+	return r.bottom - r.top
+}
+
+func (r rect) String() string {
+	return fmt.Sprintf("%d,%d %dx%d", r.left, r.top, r.width(), r.height())
+}
+
+func (r rect) offset(dx, dy int) rect {
+	return rect{
+		left:   r.left + dx,
+		top:    r.top + dy,
+		right:  r.right + dx,
+		bottom: r.bottom + dy,
+	}
+}
+
+// inset moves all edges d pixels towards the center, or outwards for negative
+// d.
+func (r rect) inset(d int) rect {
+	return rect{
+		left:   r.left + d,
+		top:    r.top + d,
+		right:  r.right - d,
+		bottom: r.bottom - d,
+	}
+}
+
+// noSelection is a selection that does not touch any tile.
+var noSelection = rect{left: -1, top: -1, right: -1, bottom: -1}
+
+// selectedTiles returns the tiles touched by the given selection, in tile
+// units. The selection is in pixels relative to the work area, which is split
+// into cols by rows tiles.
+func selectedTiles(selection rect, width, height, cols, rows int) rect {
+	tileW, tileH := width/cols, height/rows
+	return rect{
+		left:   tileIndex(selection.left, tileW, cols),
+		top:    tileIndex(selection.top, tileH, rows),
+		right:  tileIndex(selection.right, tileW, cols) + 1,
+		bottom: tileIndex(selection.bottom, tileH, rows) + 1,
+	}
+}
+
+func tileIndex(pos, tileSize, count int) int {
+	// A work area narrower than the tile count has tiles of 0 pixels, count
+	// single pixels then.
+	tileSize = max(1, tileSize)
+	i := pos / tileSize
+	if i < 0 {
+		return 0
+	}
+	if i >= count {
+		return count - 1
+	}
+	return i
+}
+
+// tilesToPixels converts a rectangle in tile units to pixels relative to the
+// work area. The last column and row absorb the remaining pixels if the work
+// area size is not divisible by the tile count.
+func tilesToPixels(tiles rect, width, height, cols, rows int) rect {
+	return rect{
+		left:   tileEdge(tiles.left, width, cols),
+		top:    tileEdge(tiles.top, height, rows),
+		right:  tileEdge(tiles.right, width, cols),
+		bottom: tileEdge(tiles.bottom, height, rows),
+	}
+}
+
+func tileEdge(i, size, count int) int {
+	if i >= count {
+		return size
+	}
+	return i * (size / count)
+}
+
+// snapToTiles returns the pixel rectangle covering all tiles touched by the
+// selection.
+func snapToTiles(selection rect, width, height, cols, rows int) rect {
+	return tilesToPixels(
+		selectedTiles(selection, width, height, cols, rows),
+		width, height, cols, rows,
+	)
+}
+
+// sizeLimits are a window's minimum and maximum track sizes in pixels, as
+// reported by WM_GETMINMAXINFO. A maximum of 0 means unlimited.
+type sizeLimits struct {
+	minWidth, minHeight int
+	maxWidth, maxHeight int
+}
+
+// sizeStrategy decides what happens when a window's minimum size is larger
+// than the tiles it is supposed to go into.
+type sizeStrategy int
+
+const (
+	// growIntoTiles adds neighbouring tiles to the selection, first to the
+	// right/bottom and then to the left/top, until the minimum size fits. If
+	// the whole work area is still too small it is handled like
+	// keepInWorkArea.
+	growIntoTiles sizeStrategy = iota
+	// keepInWorkArea enlarges the rectangle by as many pixels as needed, on
+	// both sides evenly, and then shifts it back into the work area. The size
+	// never exceeds the work area.
+	keepInWorkArea
+	// refuseToPlace does not move the window at all. The overlay tells the
+	// user why instead.
+	refuseToPlace
+)
+
+// placement is where a window goes when the user selects some tiles.
+type placement struct {
+	// tiles are the tiles the window covers, in tile units. This can be more
+	// than the selected tiles if the window's minimum size needs more space.
+	tiles rect
+	// rect is what the window is moved to, in pixels relative to the work
+	// area.
+	rect rect
+}
+
+// fitSizeLimits returns the placement of a window with the given limits in the
+// given tiles. It returns false if the strategy is refuseToPlace and the
+// window does not fit.
+//
+// Rectangles larger than the maximum size are shrunk, centered inside the
+// tiles. If the limits contradict each other, the minimum size wins.
+func fitSizeLimits(
+	tiles rect,
+	width, height, cols, rows int,
+	limits sizeLimits,
+	strategy sizeStrategy,
+) (placement, bool) {
+	r := tilesToPixels(tiles, width, height, cols, rows)
+	tooSmall := func() bool {
+		return r.width() < limits.minWidth || r.height() < limits.minHeight
+	}
+
+	if tooSmall() {
+		if strategy == refuseToPlace {
+			return placement{tiles: tiles, rect: r}, false
+		}
+		if strategy == growIntoTiles {
+			for r.width() < limits.minWidth && tiles.width() < cols {
+				if tiles.right < cols {
+					tiles.right++
+				} else {
+					tiles.left--
+				}
+				r = tilesToPixels(tiles, width, height, cols, rows)
+			}
+			for r.height() < limits.minHeight && tiles.height() < rows {
+				if tiles.bottom < rows {
+					tiles.bottom++
+				} else {
+					tiles.top--
+				}
+				r = tilesToPixels(tiles, width, height, cols, rows)
+			}
+		}
+	}
+	return placement{tiles: tiles, rect: fitPixels(r, width, height, limits)}, true
+}
+
+// fitPixels grows r to the minimum size, keeping it in the work area, and
+// shrinks it to the maximum size, keeping its center.
+func fitPixels(r rect, width, height int, limits sizeLimits) rect {
+	r.left, r.right = growSpan(r.left, r.right, limits.minWidth, width)
+	r.top, r.bottom = growSpan(r.top, r.bottom, limits.minHeight, height)
+	r.left, r.right = shrinkSpan(r.left, r.right, limits.maxWidth, limits.minWidth)
+	r.top, r.bottom = shrinkSpan(r.top, r.bottom, limits.maxHeight, limits.minHeight)
+	return r
+}
+
+// growSpan enlarges [start, end) evenly on both sides to at least min, keeping
+// it inside [0, total).
+func growSpan(start, end, min, total int) (int, int) {
+	if min > total {
+		min = total
+	}
+	if end-start >= min {
+		return start, end
+	}
+	extra := min - (end - start)
+	start -= extra / 2
+	end = start + min
+	if start < 0 {
+		start, end = 0, min
+	}
+	if end > total {
+		start, end = total-min, total
+	}
+	return start, end
+}
+
+// shrinkSpan makes [start, end) at most max long, keeping its center. A max of
+// 0 means unlimited and a max below min is raised to min.
+func shrinkSpan(start, end, max, min int) (int, int) {
+	if max <= 0 {
+		return start, end
+	}
+	if max < min {
+		max = min
+	}
+	if end-start <= max {
+		return start, end
+	}
+	start += (end - start - max) / 2
+	return start, start + max
+}
+
+// tileBounds returns the pixel rect of the tile in column x and row y as it is
+// drawn in the overlay. Tiles are inset to leave a border between them.
+func tileBounds(x, y, width, height, cols, rows int) rect {
+	w, h := width/cols, height/rows
+	return rect{
+		left:   x*w + 2,
+		top:    y*h + 2,
+		right:  (x+1)*w - 4,
+		bottom: (y+1)*h - 4,
+	}
+}
+
+// touches reports whether the selection touches the tile. The selection's
+// right and bottom are inclusive since they are the mouse position.
+func touches(tile, selection rect) bool {
+	return selection.right >= tile.left && selection.left < tile.right &&
+		selection.bottom >= tile.top && selection.top < tile.bottom
+}
+
+// changedTiles returns the bounds of all tiles that are highlighted for one of
+// the selections but not the other. Only these need to be redrawn.
+func changedTiles(old, new rect, width, height, cols, rows int) []rect {
+	var changed []rect
+	for x := 0; x < cols; x++ {
+		for y := 0; y < rows; y++ {
+			r := tileBounds(x, y, width, height, cols, rows)
+			if touches(r, old) != touches(r, new) {
+				changed = append(changed, r)
+			}
+		}
+	}
+	return changed
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// clamp returns x limited to lo..hi. If hi is less than lo, lo wins.
+func clamp(x, lo, hi int) int {
+	return max(lo, min(x, hi))
+}
@@ -0,0 +1,204 @@
+package main
+
+import "testing"
+
+func TestFitSizeLimits(t *testing.T) {
+	// The work area is split into 3x3 tiles of 400x300 pixels.
+	const width, height, cols, rows = 1200, 900, 3, 3
+	tests := []struct {
+		name      string
+		tiles     rect
+		limits    sizeLimits
+		strategy  sizeStrategy
+		wantTiles rect
+		wantRect  rect
+		wantOK    bool
+	}{
+		{
+			name:      "no limits",
+			tiles:     rect{1, 1, 2, 2},
+			wantTiles: rect{1, 1, 2, 2},
+			wantRect:  rect{400, 300, 800, 600},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than a tile grows to the right",
+			tiles:     rect{0, 0, 1, 1},
+			limits:    sizeLimits{minWidth: 500, minHeight: 200},
+			strategy:  growIntoTiles,
+			wantTiles: rect{0, 0, 2, 1},
+			wantRect:  rect{0, 0, 800, 300},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than a tile grows to the left at the edge",
+			tiles:     rect{2, 0, 3, 1},
+			limits:    sizeLimits{minWidth: 500},
+			strategy:  growIntoTiles,
+			wantTiles: rect{1, 0, 3, 1},
+			wantRect:  rect{400, 0, 1200, 300},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than a tile grows downwards",
+			tiles:     rect{1, 1, 2, 2},
+			limits:    sizeLimits{minHeight: 400},
+			strategy:  growIntoTiles,
+			wantTiles: rect{1, 1, 2, 3},
+			wantRect:  rect{400, 300, 800, 900},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than the work area",
+			tiles:     rect{0, 0, 1, 1},
+			limits:    sizeLimits{minWidth: 1500},
+			strategy:  growIntoTiles,
+			wantTiles: rect{0, 0, 3, 1},
+			wantRect:  rect{0, 0, 1200, 300},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than a tile is clamped at the monitor edge",
+			tiles:     rect{2, 2, 3, 3},
+			limits:    sizeLimits{minWidth: 500, minHeight: 400},
+			strategy:  keepInWorkArea,
+			wantTiles: rect{2, 2, 3, 3},
+			wantRect:  rect{700, 500, 1200, 900},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than a tile is clamped at the top left",
+			tiles:     rect{0, 0, 1, 1},
+			limits:    sizeLimits{minWidth: 600, minHeight: 500},
+			strategy:  keepInWorkArea,
+			wantTiles: rect{0, 0, 1, 1},
+			wantRect:  rect{0, 0, 600, 500},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than the work area is kept in it",
+			tiles:     rect{1, 0, 2, 1},
+			limits:    sizeLimits{minWidth: 2000},
+			strategy:  keepInWorkArea,
+			wantTiles: rect{1, 0, 2, 1},
+			wantRect:  rect{0, 0, 1200, 300},
+			wantOK:    true,
+		},
+		{
+			name:      "min larger than a tile is refused",
+			tiles:     rect{1, 1, 2, 2},
+			limits:    sizeLimits{minWidth: 500},
+			strategy:  refuseToPlace,
+			wantTiles: rect{1, 1, 2, 2},
+			wantRect:  rect{400, 300, 800, 600},
+			wantOK:    false,
+		},
+		{
+			name:      "max smaller than a tile is centered",
+			tiles:     rect{1, 1, 2, 2},
+			limits:    sizeLimits{maxWidth: 200, maxHeight: 100},
+			strategy:  refuseToPlace,
+			wantTiles: rect{1, 1, 2, 2},
+			wantRect:  rect{500, 400, 700, 500},
+			wantOK:    true,
+		},
+		{
+			name:      "min wins over a smaller max",
+			tiles:     rect{0, 0, 1, 1},
+			limits:    sizeLimits{minWidth: 500, maxWidth: 300},
+			strategy:  growIntoTiles,
+			wantTiles: rect{0, 0, 2, 1},
+			wantRect:  rect{150, 0, 650, 300},
+			wantOK:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := fitSizeLimits(tt.tiles, width, height, cols, rows, tt.limits, tt.strategy)
+			if ok != tt.wantOK {
+				t.Errorf("ok is %v, want %v", ok, tt.wantOK)
+			}
+			if p.tiles != tt.wantTiles {
+				t.Errorf("tiles are %v, want %v", p.tiles, tt.wantTiles)
+			}
+			if p.rect != tt.wantRect {
+				t.Errorf("rect is %v, want %v", p.rect, tt.wantRect)
+			}
+		})
+	}
+}
+
+func TestSelectedTilesInTinyWorkArea(t *testing.T) {
+	// The tiles are 0 pixels wide, this must not divide by 0.
+	got := selectedTiles(rect{1, 0, 5, 0}, 2, 100, 3, 1)
+	if want := (rect{1, 0, 3, 1}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestChangedTiles(t *testing.T) {
+	// 3x3 tiles of 100x100 pixels.
+	const width, height, cols, rows = 300, 300, 3, 3
+	tile := func(x, y int) rect {
+		return tileBounds(x, y, width, height, cols, rows)
+	}
+	tests := []struct {
+		name     string
+		old, new rect
+		want     []rect
+	}{
+		{
+			name: "identical",
+			old:  rect{10, 10, 50, 50},
+			new:  rect{10, 10, 50, 50},
+		},
+		{
+			name: "moved within a tile",
+			old:  rect{10, 10, 50, 50},
+			new:  rect{20, 20, 60, 60},
+		},
+		{
+			name: "grow",
+			old:  rect{10, 10, 50, 50},
+			new:  rect{10, 10, 150, 150},
+			want: []rect{tile(0, 1), tile(1, 0), tile(1, 1)},
+		},
+		{
+			name: "shrink",
+			old:  rect{10, 10, 150, 50},
+			new:  rect{10, 10, 50, 50},
+			want: []rect{tile(1, 0)},
+		},
+		{
+			name: "disjoint",
+			old:  rect{10, 10, 50, 50},
+			new:  rect{210, 210, 250, 250},
+			want: []rect{tile(0, 0), tile(2, 2)},
+		},
+		{
+			name: "from no selection",
+			old:  noSelection,
+			new:  rect{110, 10, 150, 50},
+			want: []rect{tile(1, 0)},
+		},
+		{
+			name: "to no selection",
+			old:  rect{10, 210, 150, 250},
+			new:  noSelection,
+			want: []rect{tile(0, 2), tile(1, 2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedTiles(tt.old, tt.new, width, height, cols, rows)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
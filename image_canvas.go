@@ -0,0 +1,126 @@
+package main
+
+import (
+	"image"
+	imagecolor "image/color"
+)
+
+// imageCanvas draws into an RGBA image. It produces the same shapes as the GDI
+// canvas, only text looks different because it uses the built-in pixel font.
+type imageCanvas struct {
+	img *image.RGBA
+}
+
+func newImageCanvas(width, height int) imageCanvas {
+	return imageCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+func (c imageCanvas) fillRoundRect(r rect, radius int, col color) {
+	for y := r.top; y < r.bottom; y++ {
+		for x := r.left; x < r.right; x++ {
+			if inRoundRect(r, radius, x, y) {
+				c.blend(x, y, col)
+			}
+		}
+	}
+}
+
+func (c imageCanvas) frameRoundRect(r rect, width, radius int, col color) {
+	if width <= 0 {
+		return
+	}
+	inner := r.inset(width)
+	// Away from the corners, only the pixels within the line width of the
+	// edges can be part of the frame.
+	edge := max(width, radius)
+	for y := r.top; y < r.bottom; y++ {
+		for x := r.left; x < r.right; x++ {
+			if y >= r.top+edge && y < r.bottom-edge && x == r.left+edge {
+				x = max(x, r.right-edge)
+			}
+			if inRoundRect(r, radius, x, y) && !inRoundRect(inner, radius-width, x, y) {
+				c.blend(x, y, col)
+			}
+		}
+	}
+}
+
+func (c imageCanvas) text(s string, r rect, t theme, size int, align textAlign) {
+	x, y := r.left, r.top
+	if align == alignCenter {
+		w, h := c.textSize(s, t, size)
+		x += (r.width() - w) / 2
+		y += (r.height() - h) / 2
+	}
+	scale := glyphScale(size)
+	for _, char := range s {
+		g := glyph(char)
+		for gy, row := range g {
+			for gx, pixel := range row {
+				if pixel == '#' {
+					c.fillRoundRect(rect{
+						left:   x + gx*scale,
+						top:    y + gy*scale,
+						right:  x + (gx+1)*scale,
+						bottom: y + (gy+1)*scale,
+					}, 0, t.Text)
+				}
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
+
+func (c imageCanvas) textSize(s string, t theme, size int) (width, height int) {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0, 0
+	}
+	scale := glyphScale(size)
+	return (n*(glyphWidth+1) - 1) * scale, glyphHeight * scale
+}
+
+// blend draws the color over the pixel at x,y.
+func (c imageCanvas) blend(x, y int, col color) {
+	if !(image.Point{x, y}).In(c.img.Rect) {
+		return
+	}
+	dst := c.img.RGBAAt(x, y)
+	a := uint32(col.a)
+	mix := func(src, dst uint8) uint8 {
+		return uint8((uint32(src)*a + uint32(dst)*(255-a)) / 255)
+	}
+	c.img.SetRGBA(x, y, imagecolor.RGBA{
+		R: mix(col.r, dst.R),
+		G: mix(col.g, dst.G),
+		B: mix(col.b, dst.B),
+		A: uint8(a + uint32(dst.A)*(255-a)/255),
+	})
+}
+
+// inRoundRect reports whether the center of pixel x,y lies inside r with
+// corners rounded by the radius.
+func inRoundRect(r rect, radius, x, y int) bool {
+	if x < r.left || x >= r.right || y < r.top || y >= r.bottom {
+		return false
+	}
+	radius = min(radius, min(r.width(), r.height())/2)
+	if radius <= 0 {
+		return true
+	}
+	// Work in half pixels to test the pixel centers with integers.
+	px, py := 2*x+1, 2*y+1
+	cx, cy := px, py
+	if px < 2*(r.left+radius) {
+		cx = 2 * (r.left + radius)
+	} else if px > 2*(r.right-radius) {
+		cx = 2 * (r.right - radius)
+	}
+	if py < 2*(r.top+radius) {
+		cy = 2 * (r.top + radius)
+	} else if py > 2*(r.bottom-radius) {
+		cy = 2 * (r.bottom - radius)
+	}
+	dx, dy := px-cx, py-cy
+	return dx*dx+dy*dy <= 4*radius*radius
+}
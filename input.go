@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type mouseButton int
+
+const (
+	leftButton mouseButton = iota
+	rightButton
+	middleButton
+)
+
+type modifiers int
+
+const (
+	ctrlKey modifiers = 1 << iota
+	shiftKey
+	altKey
+	winKey
+)
+
+type inputKind int
+
+const (
+	mouseDown inputKind = iota
+	mouseMove
+	mouseUp
+	// cancelDrag stops the current drag without placing anything, e.g. when
+	// pressing Escape.
+	cancelDrag
+)
+
+// inputEvent is a mouse event in the overlay, in pixels relative to the work
+// area. The overlay window turns its mouse messages into these so the drag
+// logic does not depend on Windows.
+type inputEvent struct {
+	kind   inputKind
+	button mouseButton
+	x, y   int
+	mods   modifiers
+}
+
+// dragAction is what a drag with a mouse button does to the window.
+type dragAction int
+
+const (
+	// placeInSelection moves the window into all tiles touched by the drag.
+	placeInSelection dragAction = iota
+	// placeFullHeight moves the window into the touched columns, covering the
+	// whole height of the work area.
+	placeFullHeight
+	// placeInTile moves the window into exactly the tile that the mouse is
+	// released over, no matter where the drag started.
+	placeInTile
+)
+
+// dragResult is what a drag asks for.
+type dragResult struct {
+	action dragAction
+	// selection is in pixels, its right and bottom are inclusive.
+	selection rect
+	// gaps leaves some space between the window and the tile borders.
+	gaps bool
+	// moveOnly keeps the window's size and only moves it to the tiles.
+	moveOnly bool
+}
+
+// inputBindings map mouse buttons to drag actions and modifier keys to how
+// they change a drag. See config.Mouse for the possible values.
+type inputBindings struct {
+	Left   string `json:"left"`
+	Right  string `json:"right"`
+	Middle string `json:"middle"`
+	Ctrl   string `json:"ctrl"`
+	Shift  string `json:"shift"`
+}
+
+func defaultInputBindings() inputBindings {
+	return inputBindings{
+		Left:   "place",
+		Right:  "place-full-height",
+		Middle: "place-tile",
+		Ctrl:   "toggle-gaps",
+		Shift:  "move-only",
+	}
+}
+
+func (b inputBindings) validate() error {
+	for _, button := range []string{b.Left, b.Right, b.Middle} {
+		if _, err := parseDragAction(button); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{b.Ctrl, b.Shift} {
+		if key != "" && key != "toggle-gaps" && key != "move-only" {
+			return fmt.Errorf(
+				`invalid modifier action %q, use "toggle-gaps", "move-only" or ""`,
+				key,
+			)
+		}
+	}
+	return nil
+}
+
+func (b inputBindings) action(button mouseButton) dragAction {
+	name := b.Left
+	if button == rightButton {
+		name = b.Right
+	} else if button == middleButton {
+		name = b.Middle
+	}
+	a, _ := parseDragAction(name)
+	return a
+}
+
+// parseModifier parses the name of a single modifier key.
+func parseModifier(s string) (modifiers, error) {
+	switch strings.ToLower(s) {
+	case "ctrl":
+		return ctrlKey, nil
+	case "shift":
+		return shiftKey, nil
+	case "alt":
+		return altKey, nil
+	}
+	return 0, fmt.Errorf(`invalid modifier %q, use "ctrl", "shift" or "alt"`, s)
+}
+
+func parseDragAction(s string) (dragAction, error) {
+	switch strings.ToLower(s) {
+	case "place":
+		return placeInSelection, nil
+	case "place-full-height":
+		return placeFullHeight, nil
+	case "place-tile":
+		return placeInTile, nil
+	}
+	return 0, fmt.Errorf(
+		`invalid mouse action %q, use "place", "place-full-height" or "place-tile"`,
+		s,
+	)
+}
+
+// dragState is where a drag is in its lifecycle:
+//
+//	state      event                      next state
+//	idle       mouseDown                  selecting
+//	selecting  mouseMove                  selecting, selection follows
+//	selecting  mouseUp of its button      idle, the drag is finished
+//	selecting  mouseDown of other button  idle, the drag is cancelled
+//	selecting  cancelDrag                 idle, the drag is cancelled
+//
+// All other events leave the state as it is.
+type dragState int
+
+const (
+	dragIdle dragState = iota
+	dragSelecting
+)
+
+// dragTracker turns a stream of input events into drags.
+type dragTracker struct {
+	bindings inputBindings
+	// gapsByDefault is whether drags use gaps without the toggle modifier.
+	gapsByDefault bool
+	// width and height are the size of the grid in pixels. Events outside
+	// of it, which happen while the mouse is captured, are clamped into it.
+	width, height int
+
+	state     dragState
+	button    mouseButton
+	selection rect
+	// anchorX and anchorY are where the drag started, the selection spans
+	// from there to the mouse.
+	anchorX int
+	anchorY int
+	lastX   int
+	lastY   int
+	mods    modifiers
+}
+
+func (t *dragTracker) active() bool {
+	return t.state == dragSelecting
+}
+
+// handle processes the next event. It returns true along with the result
+// when a drag is finished.
+func (t *dragTracker) handle(e inputEvent) (dragResult, bool) {
+	e.x = clamp(e.x, 0, t.width-1)
+	e.y = clamp(e.y, 0, t.height-1)
+	switch t.state {
+	case dragIdle:
+		if e.kind == mouseDown {
+			t.state = dragSelecting
+			t.button = e.button
+			t.anchorX, t.anchorY = e.x, e.y
+			t.selection = rect{left: e.x, top: e.y, right: e.x, bottom: e.y}
+		}
+	case dragSelecting:
+		switch e.kind {
+		case mouseDown, cancelDrag:
+			t.state = dragIdle
+			return dragResult{}, false
+		case mouseMove:
+			t.selection = rect{
+				left:   min(t.anchorX, e.x),
+				top:    min(t.anchorY, e.y),
+				right:  max(t.anchorX, e.x),
+				bottom: max(t.anchorY, e.y),
+			}
+		case mouseUp:
+			if e.button == t.button {
+				t.lastX, t.lastY, t.mods = e.x, e.y, e.mods
+				t.state = dragIdle
+				return t.result(), true
+			}
+		}
+	}
+	t.lastX, t.lastY, t.mods = e.x, e.y, e.mods
+	return dragResult{}, false
+}
+
+// current returns what the drag would do if it was finished now.
+func (t *dragTracker) current() (dragResult, bool) {
+	if !t.active() {
+		return dragResult{}, false
+	}
+	return t.result(), true
+}
+
+func (t *dragTracker) result() dragResult {
+	r := dragResult{
+		action:    t.bindings.action(t.button),
+		selection: t.selection,
+		gaps:      t.gapsByDefault,
+	}
+	if r.action == placeInTile {
+		r.selection = rect{left: t.lastX, top: t.lastY, right: t.lastX, bottom: t.lastY}
+	}
+	for _, m := range []struct {
+		key    modifiers
+		action string
+	}{
+		{ctrlKey, t.bindings.Ctrl},
+		{shiftKey, t.bindings.Shift},
+	} {
+		if t.mods&m.key == 0 {
+			continue
+		}
+		switch m.action {
+		case "toggle-gaps":
+			r.gaps = !r.gaps
+		case "move-only":
+			r.moveOnly = true
+		}
+	}
+	return r
+}
+
+// dragOptions are the settings that influence where a drag puts a window.
+type dragOptions struct {
+	width, height int
+	cols, rows    int
+	limits        sizeLimits
+	strategy      sizeStrategy
+	gap           int
+	// windowWidth and windowHeight are the window's current size, used for
+	// moveOnly.
+	windowWidth, windowHeight int
+}
+
+// planDrag returns where the window goes for the drag.
+func planDrag(d dragResult, o dragOptions) (placement, bool) {
+	tiles := selectedTiles(d.selection, o.width, o.height, o.cols, o.rows)
+	if d.action == placeFullHeight {
+		tiles.top, tiles.bottom = 0, o.rows
+	}
+
+	if d.moveOnly {
+		r := tilesToPixels(tiles, o.width, o.height, o.cols, o.rows)
+		w := min(o.windowWidth, o.width)
+		h := min(o.windowHeight, o.height)
+		left := min(r.left, o.width-w)
+		top := min(r.top, o.height-h)
+		return placement{
+			tiles: tiles,
+			rect:  rect{left: left, top: top, right: left + w, bottom: top + h},
+		}, true
+	}
+
+	return placeTiles(tiles, d.gaps, o)
+}
+
+// placeTiles returns where a window goes in the tiles.
+func placeTiles(tiles rect, gaps bool, o dragOptions) (placement, bool) {
+	p, ok := fitSizeLimits(tiles, o.width, o.height, o.cols, o.rows, o.limits, o.strategy)
+	if ok && gaps {
+		r := withGaps(p.rect, o.gap, o.width, o.height)
+		if r.width() >= o.limits.minWidth && r.height() >= o.limits.minHeight {
+			p.rect = r
+		}
+	}
+	return p, ok
+}
+
+// withGaps shrinks r so that windows in neighbouring tiles are gap pixels
+// apart, and gap pixels away from the work area borders.
+func withGaps(r rect, gap, width, height int) rect {
+	edge := func(pos, limit int) int {
+		if pos == 0 || pos == limit {
+			return gap
+		}
+		return gap / 2
+	}
+	return rect{
+		left:   r.left + edge(r.left, width),
+		top:    r.top + edge(r.top, height),
+		right:  r.right - edge(r.right, width),
+		bottom: r.bottom - edge(r.bottom, height),
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import "testing"
+
+func pressAt(b mouseButton, x, y int) inputEvent {
+	return inputEvent{kind: mouseDown, button: b, x: x, y: y}
+}
+
+func moveTo(x, y int) inputEvent {
+	return inputEvent{kind: mouseMove, x: x, y: y}
+}
+
+func releaseAt(b mouseButton, x, y int, mods modifiers) inputEvent {
+	return inputEvent{kind: mouseUp, button: b, x: x, y: y, mods: mods}
+}
+
+// feed sends the events to the tracker and returns the drags that finished.
+func feed(t *dragTracker, events ...inputEvent) []dragResult {
+	var finished []dragResult
+	for _, e := range events {
+		if r, ok := t.handle(e); ok {
+			finished = append(finished, r)
+		}
+	}
+	return finished
+}
+
+func TestDragBindings(t *testing.T) {
+	custom := inputBindings{
+		Left:   "place-tile",
+		Right:  "place",
+		Middle: "place-full-height",
+		Ctrl:   "move-only",
+		Shift:  "",
+	}
+	tests := []struct {
+		name          string
+		bindings      inputBindings
+		gapsByDefault bool
+		events        []inputEvent
+		want          dragResult
+	}{
+		{
+			name:     "left drag places in the selection",
+			bindings: defaultInputBindings(),
+			events:   []inputEvent{pressAt(leftButton, 10, 10), moveTo(150, 60), releaseAt(leftButton, 150, 60, 0)},
+			want:     dragResult{action: placeInSelection, selection: rect{10, 10, 150, 60}},
+		},
+		{
+			name:     "right drag places in full height",
+			bindings: defaultInputBindings(),
+			events:   []inputEvent{pressAt(rightButton, 150, 60), moveTo(10, 10), releaseAt(rightButton, 10, 10, 0)},
+			want:     dragResult{action: placeFullHeight, selection: rect{10, 10, 150, 60}},
+		},
+		{
+			name:     "middle click places in the tile under the mouse",
+			bindings: defaultInputBindings(),
+			events:   []inputEvent{pressAt(middleButton, 10, 10), moveTo(200, 100), releaseAt(middleButton, 250, 150, 0)},
+			want:     dragResult{action: placeInTile, selection: rect{250, 150, 250, 150}},
+		},
+		{
+			name:     "ctrl toggles gaps on",
+			bindings: defaultInputBindings(),
+			events:   []inputEvent{pressAt(leftButton, 10, 10), releaseAt(leftButton, 10, 10, ctrlKey)},
+			want:     dragResult{action: placeInSelection, selection: rect{10, 10, 10, 10}, gaps: true},
+		},
+		{
+			name:          "ctrl toggles gaps off",
+			bindings:      defaultInputBindings(),
+			gapsByDefault: true,
+			events:        []inputEvent{pressAt(leftButton, 10, 10), releaseAt(leftButton, 10, 10, ctrlKey)},
+			want:          dragResult{action: placeInSelection, selection: rect{10, 10, 10, 10}},
+		},
+		{
+			name:     "shift moves only",
+			bindings: defaultInputBindings(),
+			events:   []inputEvent{pressAt(leftButton, 10, 10), releaseAt(leftButton, 10, 10, shiftKey)},
+			want:     dragResult{action: placeInSelection, selection: rect{10, 10, 10, 10}, moveOnly: true},
+		},
+		{
+			name:     "modifiers count when the drag ends",
+			bindings: defaultInputBindings(),
+			events: []inputEvent{
+				{kind: mouseDown, button: leftButton, x: 10, y: 10, mods: ctrlKey | shiftKey},
+				releaseAt(leftButton, 10, 10, 0),
+			},
+			want: dragResult{action: placeInSelection, selection: rect{10, 10, 10, 10}},
+		},
+		{
+			name:     "custom left button",
+			bindings: custom,
+			events:   []inputEvent{pressAt(leftButton, 10, 10), moveTo(150, 60), releaseAt(leftButton, 150, 60, 0)},
+			want:     dragResult{action: placeInTile, selection: rect{150, 60, 150, 60}},
+		},
+		{
+			name:     "custom middle button",
+			bindings: custom,
+			events:   []inputEvent{pressAt(middleButton, 10, 10), releaseAt(middleButton, 10, 10, 0)},
+			want:     dragResult{action: placeFullHeight, selection: rect{10, 10, 10, 10}},
+		},
+		{
+			name:     "custom ctrl",
+			bindings: custom,
+			events:   []inputEvent{pressAt(rightButton, 10, 10), releaseAt(rightButton, 10, 10, ctrlKey)},
+			want:     dragResult{action: placeInSelection, selection: rect{10, 10, 10, 10}, moveOnly: true},
+		},
+		{
+			name:     "unbound shift",
+			bindings: custom,
+			events:   []inputEvent{pressAt(rightButton, 10, 10), releaseAt(rightButton, 10, 10, shiftKey)},
+			want:     dragResult{action: placeInSelection, selection: rect{10, 10, 10, 10}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &dragTracker{
+				bindings:      tt.bindings,
+				gapsByDefault: tt.gapsByDefault,
+				width:         300,
+				height:        300,
+			}
+			got := feed(tracker, tt.events...)
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanDragFullHeight(t *testing.T) {
+	d := dragResult{action: placeFullHeight, selection: rect{10, 110, 150, 120}}
+	p, ok := planDrag(d, dragOptions{width: 300, height: 300, cols: 3, rows: 3})
+	if !ok {
+		t.Fatal("the drag was refused")
+	}
+	if want := (rect{0, 0, 2, 3}); p.tiles != want {
+		t.Errorf("tiles are %v, want %v", p.tiles, want)
+	}
+	if want := (rect{0, 0, 200, 300}); p.rect != want {
+		t.Errorf("rect is %v, want %v", p.rect, want)
+	}
+}
+
+func TestPlanDragMoveOnly(t *testing.T) {
+	d := dragResult{action: placeInSelection, selection: rect{250, 250, 250, 250}, moveOnly: true}
+	p, _ := planDrag(d, dragOptions{
+		width: 300, height: 300, cols: 3, rows: 3,
+		windowWidth: 150, windowHeight: 80,
+	})
+	// The window keeps its size and is shifted back into the work area.
+	if want := (rect{150, 200, 300, 280}); p.rect != want {
+		t.Errorf("rect is %v, want %v", p.rect, want)
+	}
+}
+
+func TestDragTransitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []inputEvent
+		// wantState is the state after the events.
+		wantState dragState
+		// wantDrags are the selections of the finished drags.
+		wantDrags []rect
+		// wantSelection is the selection of the unfinished drag.
+		wantSelection rect
+	}{
+		{
+			name:      "idle ignores moves",
+			events:    []inputEvent{moveTo(10, 10)},
+			wantState: dragIdle,
+		},
+		{
+			name:      "idle ignores mouse up",
+			events:    []inputEvent{releaseAt(leftButton, 10, 10, 0)},
+			wantState: dragIdle,
+		},
+		{
+			name:      "idle ignores cancel",
+			events:    []inputEvent{{kind: cancelDrag}},
+			wantState: dragIdle,
+		},
+		{
+			name:          "mouse down starts selecting",
+			events:        []inputEvent{pressAt(leftButton, 10, 20)},
+			wantState:     dragSelecting,
+			wantSelection: rect{10, 20, 10, 20},
+		},
+		{
+			name:          "moves span from the anchor",
+			events:        []inputEvent{pressAt(leftButton, 100, 100), moveTo(150, 120), moveTo(20, 30)},
+			wantState:     dragSelecting,
+			wantSelection: rect{20, 30, 100, 100},
+		},
+		{
+			name:          "mouse up of another button is ignored",
+			events:        []inputEvent{pressAt(leftButton, 10, 10), moveTo(50, 50), releaseAt(rightButton, 50, 50, 0)},
+			wantState:     dragSelecting,
+			wantSelection: rect{10, 10, 50, 50},
+		},
+		{
+			name:      "mouse up of its button finishes",
+			events:    []inputEvent{pressAt(leftButton, 10, 10), moveTo(50, 50), releaseAt(leftButton, 50, 50, 0)},
+			wantState: dragIdle,
+			wantDrags: []rect{{10, 10, 50, 50}},
+		},
+		{
+			name: "mouse down of another button cancels",
+			events: []inputEvent{
+				pressAt(leftButton, 10, 10), moveTo(50, 50),
+				pressAt(rightButton, 50, 50),
+				releaseAt(rightButton, 50, 50, 0), releaseAt(leftButton, 50, 50, 0),
+			},
+			wantState: dragIdle,
+		},
+		{
+			name: "cancel stops the drag",
+			events: []inputEvent{
+				pressAt(leftButton, 10, 10), moveTo(50, 50),
+				{kind: cancelDrag},
+				releaseAt(leftButton, 50, 50, 0),
+			},
+			wantState: dragIdle,
+		},
+		{
+			name: "a new drag after cancelling",
+			events: []inputEvent{
+				pressAt(leftButton, 10, 10), {kind: cancelDrag},
+				pressAt(leftButton, 30, 30), releaseAt(leftButton, 40, 40, 0),
+			},
+			wantState: dragIdle,
+			wantDrags: []rect{{30, 30, 30, 30}},
+		},
+		{
+			name:          "events outside of the grid are clamped",
+			events:        []inputEvent{pressAt(leftButton, -5, 10), moveTo(5000, -20)},
+			wantState:     dragSelecting,
+			wantSelection: rect{0, 0, 299, 10},
+		},
+		{
+			name:      "mouse up outside of the grid finishes",
+			events:    []inputEvent{pressAt(leftButton, 100, 100), moveTo(400, 400), releaseAt(leftButton, 400, 400, 0)},
+			wantState: dragIdle,
+			wantDrags: []rect{{100, 100, 299, 199}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &dragTracker{bindings: defaultInputBindings(), width: 300, height: 200}
+			got := feed(tracker, tt.events...)
+			if tracker.state != tt.wantState {
+				t.Errorf("state is %v, want %v", tracker.state, tt.wantState)
+			}
+			if len(got) != len(tt.wantDrags) {
+				t.Fatalf("finished %+v, want selections %v", got, tt.wantDrags)
+			}
+			for i := range got {
+				if got[i].selection != tt.wantDrags[i] {
+					t.Errorf("drag %d selected %v, want %v", i, got[i].selection, tt.wantDrags[i])
+				}
+			}
+			current, active := tracker.current()
+			if active != (tt.wantState == dragSelecting) {
+				t.Errorf("active is %v, want %v", active, !active)
+			}
+			if active && current.selection != tt.wantSelection {
+				t.Errorf("selection is %v, want %v", current.selection, tt.wantSelection)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// In resident mode, other programs like editors, launchers or scripts control
+// tile_screen over a named pipe. The protocol is line-delimited JSON: each
+// line that the client writes is an ipcRequest, the server answers every
+// request with one line holding an ipcResponse, in the order of the
+// requests. For example:
+//
+//	{"command":"place","tiles":"1,1-2,1","grid":"3x2"}
+//	{"ok":true,"result":{"handle":263350,...}}
+//
+// Nothing in this file knows about the transport, listenIPC and dialIPC
+// provide it.
+
+// ipcRequest is a command sent to the resident instance. Which fields are
+// used depends on the command.
+type ipcRequest struct {
+	// ID is sent back in the response unchanged, it can be any JSON value.
+	ID      json.RawMessage `json:"id,omitempty"`
+	Command string          `json:"command"`
+	// Window is the handle of the window to place, the active window by
+	// default.
+	Window uint64 `json:"window,omitempty"`
+	// Tiles are the tiles to place the window into, like "1,1-2,1" from
+	// column 1 row 1 to column 2 row 1.
+	Tiles string `json:"tiles,omitempty"`
+	// Grid is the grid that Tiles refer to, like "3x2", the grid of the
+	// monitor from the config by default.
+	Grid string `json:"grid,omitempty"`
+	// Monitor is the id of the monitor to place the window on, see
+	// list-monitors, the window's monitor by default.
+	Monitor string `json:"monitor,omitempty"`
+	// Name is the name of the workspace.
+	Name string `json:"name,omitempty"`
+	// Desktop is the virtual desktop that the workspace was saved on, by
+	// number, name or id, the current one by default.
+	Desktop string `json:"desktop,omitempty"`
+}
+
+// ipcResponse answers an ipcRequest. Result depends on the command, it is
+// left out on errors and for commands without a result.
+type ipcResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	OK     bool            `json:"ok"`
+	Error  string          `json:"error,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+}
+
+type ipcRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func toIPCRect(r rect) ipcRect {
+	return ipcRect{X: r.left, Y: r.top, Width: r.width(), Height: r.height()}
+}
+
+func (r ipcRect) rect() rect {
+	return rect{left: r.X, top: r.Y, right: r.X + r.Width, bottom: r.Y + r.Height}
+}
+
+// ipcWindow is a window in the result of list-windows and place.
+type ipcWindow struct {
+	Handle uint64 `json:"handle"`
+	App    string `json:"app"`
+	Class  string `json:"class"`
+	Title  string `json:"title"`
+	// Desktop is the id of the virtual desktop that the window is on, "" for
+	// windows pinned to all desktops.
+	Desktop string `json:"desktop"`
+	// Monitor is the id of the monitor that most of the window is on.
+	Monitor string `json:"monitor"`
+	// Bounds are in physical pixels on the virtual screen.
+	Bounds ipcRect `json:"bounds"`
+	State  string  `json:"state"`
+}
+
+// ipcMonitor is a monitor in the result of list-monitors. It also has what
+// the list-monitors command prints.
+type ipcMonitor struct {
+	ID      string `json:"id"`
+	Device  string `json:"device"`
+	Name    string `json:"name"`
+	Primary bool   `json:"primary"`
+	// Bounds and Work are in physical pixels on the virtual screen.
+	Bounds ipcRect `json:"bounds"`
+	Work   ipcRect `json:"work"`
+	DPI    int     `json:"dpi"`
+	// Grid is the grid that the config gives the monitor.
+	Grid string `json:"grid"`
+}
+
+type savedWorkspaceResult struct {
+	Name    string `json:"name"`
+	Desktop string `json:"desktop"`
+	Windows int    `json:"windows"`
+}
+
+type restoredWorkspaceResult struct {
+	Restored int `json:"restored"`
+	// Missing are the titles of the saved windows that are not open.
+	Missing []string `json:"missing"`
+}
+
+// placeRequest is a place request after checking its fields.
+type placeRequest struct {
+	// window is 0 for the active window.
+	window  uint64
+	monitor string
+	// cols and rows are 0 for the grid of the monitor.
+	cols, rows int
+	// tiles still have to be parsed in the final grid.
+	tiles string
+}
+
+// ipcHandler carries out the requests. Resident mode implements it on
+// Windows.
+type ipcHandler interface {
+	place(p placeRequest) (ipcWindow, error)
+	listWindows() ([]ipcWindow, error)
+	listMonitors() ([]ipcMonitor, error)
+	saveWorkspace(name string) (workspace, error)
+	restoreWorkspace(name, desktop string) (restored int, missing []string, err error)
+	showOverlay() error
+}
+
+// dispatchIPC runs the request with the handler and turns the outcome into
+// its response.
+func dispatchIPC(h ipcHandler, req ipcRequest) ipcResponse {
+	result, err := runIPCRequest(h, req)
+	if err != nil {
+		return ipcResponse{ID: req.ID, Error: err.Error()}
+	}
+	return ipcResponse{ID: req.ID, OK: true, Result: result}
+}
+
+func runIPCRequest(h ipcHandler, req ipcRequest) (interface{}, error) {
+	switch req.Command {
+	case "place":
+		if req.Tiles == "" {
+			return nil, errors.New("place needs the tiles")
+		}
+		p := placeRequest{window: req.Window, monitor: req.Monitor, tiles: req.Tiles}
+		if req.Grid != "" {
+			var err error
+			p.cols, p.rows, err = parseDims(req.Grid)
+			if err != nil {
+				return nil, fmt.Errorf("invalid grid: %w", err)
+			}
+			if p.cols > maxGridSize || p.rows > maxGridSize {
+				return nil, fmt.Errorf("grid %s must be at most %dx%d", req.Grid, maxGridSize, maxGridSize)
+			}
+		}
+		return h.place(p)
+	case "list-windows":
+		return h.listWindows()
+	case "list-monitors":
+		return h.listMonitors()
+	case "save-workspace":
+		name := req.Name
+		if name == "" {
+			name = defaultWorkspace
+		}
+		w, err := h.saveWorkspace(name)
+		if err != nil {
+			return nil, err
+		}
+		return savedWorkspaceResult{Name: w.Name, Desktop: w.Desktop, Windows: len(w.Windows)}, nil
+	case "restore-workspace":
+		name := req.Name
+		if name == "" {
+			name = defaultWorkspace
+		}
+		restored, missing, err := h.restoreWorkspace(name, req.Desktop)
+		if err != nil {
+			return nil, err
+		}
+		if missing == nil {
+			missing = []string{}
+		}
+		return restoredWorkspaceResult{Restored: restored, Missing: missing}, nil
+	case "show-overlay":
+		return nil, h.showOverlay()
+	case "":
+		return nil, errors.New("the request has no command")
+	}
+	return nil, fmt.Errorf("unknown command %q", req.Command)
+}
+
+// maxIPCLine limits the size of a request.
+const maxIPCLine = 1 << 20
+
+// decodeIPCRequest parses one line of the protocol. Unknown fields are an
+// error, they are most likely typos.
+func decodeIPCRequest(line []byte) (ipcRequest, error) {
+	var req ipcRequest
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid request: %w", err)
+	}
+	if dec.More() {
+		return req, errors.New("invalid request: there must be one JSON object per line")
+	}
+	return req, nil
+}
+
+// serveIPCConn answers the requests on the connection, one after the other,
+// until the client closes it. It closes the connection when done.
+func serveIPCConn(conn io.ReadWriteCloser, handle func(ipcRequest) ipcResponse) error {
+	defer conn.Close()
+	lines := bufio.NewScanner(conn)
+	lines.Buffer(nil, maxIPCLine)
+	enc := json.NewEncoder(conn)
+	for lines.Scan() {
+		line := bytes.TrimSpace(lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		req, err := decodeIPCRequest(line)
+		resp := ipcResponse{ID: req.ID}
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp = handle(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return lines.Err()
+}
+
+// errIPCClosed is returned by ipcListener.accept after the listener was
+// closed.
+var errIPCClosed = errors.New("the IPC listener is closed")
+
+// ipcListener accepts connections from clients.
+type ipcListener interface {
+	accept() (io.ReadWriteCloser, error)
+	close() error
+}
+
+// serveIPC serves every connection that the listener accepts in its own
+// goroutine, until the listener is closed. handle is called from these
+// goroutines.
+func serveIPC(l ipcListener, handle func(ipcRequest) ipcResponse) {
+	for {
+		conn, err := l.accept()
+		if err != nil {
+			if !errors.Is(err, errIPCClosed) {
+				log.errorf("no longer taking commands from other programs: %v", err)
+			}
+			return
+		}
+		go func() {
+			if err := serveIPCConn(conn, handle); err != nil {
+				log.warnf("IPC connection failed: %v", err)
+			}
+		}()
+	}
+}
+
+// sendIPCRequests sends the requests, one JSON object per line, to the
+// resident instance and writes its responses to stdout. It returns the error
+// of the first request that failed.
+func sendIPCRequests(requests []string, stdout io.Writer) error {
+	conn, err := dialIPC()
+	if err != nil {
+		return fmt.Errorf("unable to reach tile_screen in resident mode, is it running? %w", err)
+	}
+	defer conn.Close()
+	responses := bufio.NewScanner(conn)
+	responses.Buffer(nil, maxIPCLine)
+	var firstErr error
+	for _, req := range requests {
+		if _, err := io.WriteString(conn, req+"\n"); err != nil {
+			return err
+		}
+		if !responses.Scan() {
+			if err := responses.Err(); err != nil {
+				return err
+			}
+			return io.ErrUnexpectedEOF
+		}
+		fmt.Fprintln(stdout, responses.Text())
+		var resp ipcResponse
+		if err := json.Unmarshal(responses.Bytes(), &resp); err == nil && !resp.OK && firstErr == nil {
+			firstErr = errors.New(resp.Error)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeIPCHandler records the calls that reach it and fails all of them with
+// err if it is set.
+type fakeIPCHandler struct {
+	calls []string
+	err   error
+}
+
+func (h *fakeIPCHandler) place(p placeRequest) (ipcWindow, error) {
+	h.calls = append(h.calls, fmt.Sprintf(
+		"place window=%d monitor=%q grid=%dx%d tiles=%q",
+		p.window, p.monitor, p.cols, p.rows, p.tiles,
+	))
+	return ipcWindow{Handle: p.window, Title: "Notepad", State: "normal"}, h.err
+}
+
+func (h *fakeIPCHandler) listWindows() ([]ipcWindow, error) {
+	h.calls = append(h.calls, "list-windows")
+	return []ipcWindow{}, h.err
+}
+
+func (h *fakeIPCHandler) listMonitors() ([]ipcMonitor, error) {
+	h.calls = append(h.calls, "list-monitors")
+	return []ipcMonitor{{ID: "DEL4109/UID4353", Primary: true, DPI: 96, Grid: "3x2"}}, h.err
+}
+
+func (h *fakeIPCHandler) saveWorkspace(name string) (workspace, error) {
+	h.calls = append(h.calls, "save-workspace "+name)
+	return workspace{Name: name, Desktop: "{D}", Windows: make([]savedWindow, 2)}, h.err
+}
+
+func (h *fakeIPCHandler) restoreWorkspace(name, desktop string) (int, []string, error) {
+	h.calls = append(h.calls, fmt.Sprintf("restore-workspace %s desktop=%q", name, desktop))
+	return 3, nil, h.err
+}
+
+func (h *fakeIPCHandler) showOverlay() error {
+	h.calls = append(h.calls, "show-overlay")
+	return h.err
+}
+
+// exchangeIPC sends the lines to serveIPCConn over a pipe and returns the
+// responses. Blank lines have no response.
+func exchangeIPC(t *testing.T, h ipcHandler, lines ...string) []string {
+	t.Helper()
+	client, server := net.Pipe()
+	served := make(chan error)
+	go func() {
+		served <- serveIPCConn(server, func(req ipcRequest) ipcResponse {
+			return dispatchIPC(h, req)
+		})
+	}()
+	responses := bufio.NewReader(client)
+	var got []string
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(client, line); err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		resp, err := responses.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSuffix(resp, "\n"))
+	}
+	client.Close()
+	if err := <-served; err != nil {
+		t.Errorf("serving the connection failed: %v", err)
+	}
+	return got
+}
+
+func TestServeIPCConn(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   string
+		response  string
+		wantCalls []string
+	}{
+		{
+			name:     "place",
+			request:  `{"id":1,"command":"place","window":42,"tiles":"1,1-2,1","grid":"3x2","monitor":"DEL4109/UID4353"}`,
+			response: `{"id":1,"ok":true,"result":{"handle":42,"app":"","class":"","title":"Notepad","desktop":"","monitor":"","bounds":{"x":0,"y":0,"width":0,"height":0},"state":"normal"}}`,
+			wantCalls: []string{
+				`place window=42 monitor="DEL4109/UID4353" grid=3x2 tiles="1,1-2,1"`,
+			},
+		},
+		{
+			name:      "place with the grid of the monitor",
+			request:   `{"command":"place","tiles":"2,1"}`,
+			response:  `{"ok":true,"result":{"handle":0,"app":"","class":"","title":"Notepad","desktop":"","monitor":"","bounds":{"x":0,"y":0,"width":0,"height":0},"state":"normal"}}`,
+			wantCalls: []string{`place window=0 monitor="" grid=0x0 tiles="2,1"`},
+		},
+		{
+			name:     "place without tiles",
+			request:  `{"id":"a","command":"place"}`,
+			response: `{"id":"a","ok":false,"error":"place needs the tiles"}`,
+		},
+		{
+			name:     "place with an invalid grid",
+			request:  `{"command":"place","tiles":"1,1","grid":"3"}`,
+			response: `{"ok":false,"error":"invalid grid: \"3\" is not of the form AxB"}`,
+		},
+		{
+			name:     "place with a grid that is too large",
+			request:  `{"command":"place","tiles":"1,1","grid":"10x2"}`,
+			response: `{"ok":false,"error":"grid 10x2 must be at most 9x9"}`,
+		},
+		{
+			name:      "list-windows",
+			request:   `{"command":"list-windows"}`,
+			response:  `{"ok":true,"result":[]}`,
+			wantCalls: []string{"list-windows"},
+		},
+		{
+			name:      "list-monitors",
+			request:   `{"command":"list-monitors"}`,
+			response:  `{"ok":true,"result":[{"id":"DEL4109/UID4353","device":"","name":"","primary":true,"bounds":{"x":0,"y":0,"width":0,"height":0},"work":{"x":0,"y":0,"width":0,"height":0},"dpi":96,"grid":"3x2"}]}`,
+			wantCalls: []string{"list-monitors"},
+		},
+		{
+			name:      "save-workspace",
+			request:   `{"command":"save-workspace"}`,
+			response:  `{"ok":true,"result":{"name":"default","desktop":"{D}","windows":2}}`,
+			wantCalls: []string{"save-workspace default"},
+		},
+		{
+			name:      "restore-workspace",
+			request:   `{"command":"restore-workspace","name":"coding","desktop":"2"}`,
+			response:  `{"ok":true,"result":{"restored":3,"missing":[]}}`,
+			wantCalls: []string{`restore-workspace coding desktop="2"`},
+		},
+		{
+			name:      "show-overlay",
+			request:   `{"command":"show-overlay"}`,
+			response:  `{"ok":true}`,
+			wantCalls: []string{"show-overlay"},
+		},
+		{
+			name:     "no command",
+			request:  `{"id":[1,2]}`,
+			response: `{"id":[1,2],"ok":false,"error":"the request has no command"}`,
+		},
+		{
+			name:     "unknown command",
+			request:  `{"command":"dance"}`,
+			response: `{"ok":false,"error":"unknown command \"dance\""}`,
+		},
+		{
+			name:     "unknown field",
+			request:  `{"command":"place","tiles":"1,1","move":true}`,
+			response: `{"ok":false,"error":"invalid request: json: unknown field \"move\""}`,
+		},
+		{
+			name:     "two objects on one line",
+			request:  `{"command":"list-windows"} {"command":"list-monitors"}`,
+			response: `{"ok":false,"error":"invalid request: there must be one JSON object per line"}`,
+		},
+		{
+			name:     "not JSON",
+			request:  `place 1,1`,
+			response: `{"ok":false,"error":"invalid request: invalid character 'p' looking for beginning of value"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &fakeIPCHandler{}
+			got := exchangeIPC(t, h, tt.request)
+			if len(got) != 1 || got[0] != tt.response {
+				t.Errorf("got %q, want %q", got, tt.response)
+			}
+			if !reflect.DeepEqual(h.calls, tt.wantCalls) {
+				t.Errorf("got calls %q, want %q", h.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestServeIPCConnAnswersInOrder(t *testing.T) {
+	h := &fakeIPCHandler{}
+	got := exchangeIPC(t, h,
+		`{"id":1,"command":"show-overlay"}`,
+		"",
+		"   ",
+		`{"id":2,"command":"nothing"}`,
+		`{"id":3,"command":"list-windows"}`,
+	)
+	want := []string{
+		`{"id":1,"ok":true}`,
+		`{"id":2,"ok":false,"error":"unknown command \"nothing\""}`,
+		`{"id":3,"ok":true,"result":[]}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if want := []string{"show-overlay", "list-windows"}; !reflect.DeepEqual(h.calls, want) {
+		t.Errorf("got calls %q, want %q", h.calls, want)
+	}
+}
+
+func TestDispatchIPCErrors(t *testing.T) {
+	h := &fakeIPCHandler{err: errors.New("there is no active window")}
+	for _, command := range []string{"place", "list-windows", "list-monitors", "save-workspace", "restore-workspace", "show-overlay"} {
+		resp := dispatchIPC(h, ipcRequest{ID: []byte(`7`), Command: command, Tiles: "1,1"})
+		want := ipcResponse{ID: []byte(`7`), Error: "there is no active window"}
+		if !reflect.DeepEqual(resp, want) {
+			t.Errorf("%s: got %+v, want %+v", command, resp, want)
+		}
+	}
+}
+
+func TestServeIPCConnLineTooLong(t *testing.T) {
+	client, server := net.Pipe()
+	served := make(chan error)
+	go func() {
+		served <- serveIPCConn(server, func(req ipcRequest) ipcResponse {
+			t.Error("the request was handled")
+			return ipcResponse{}
+		})
+	}()
+	go func() {
+		fmt.Fprintf(client, `{"command":"%s"}`+"\n", strings.Repeat("x", maxIPCLine))
+	}()
+	if err := <-served; err != bufio.ErrTooLong {
+		t.Errorf("got %v, want %v", err, bufio.ErrTooLong)
+	}
+	client.Close()
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32"
+)
+
+// ipcPipeName is the named pipe of the resident instance. Every session has
+// its own, so users that are logged in at the same time only reach their own
+// instance.
+func ipcPipeName() string {
+	var session uint32
+	processIdToSessionId.Call(
+		uintptr(w32.GetCurrentProcessId()),
+		uintptr(unsafe.Pointer(&session)),
+	)
+	return `\\.\pipe\tile_screen-` + strconv.Itoa(int(session))
+}
+
+// pipeListener accepts clients on instances of the named pipe. There is
+// always one instance waiting for the next client.
+type pipeListener struct {
+	name   string
+	next   syscall.Handle
+	closed atomic.Bool
+}
+
+func listenIPC() (ipcListener, error) {
+	l := &pipeListener{name: ipcPipeName()}
+	// Only one resident instance can own the pipe.
+	next, err := l.createInstance(fileFlagFirstPipeInstance)
+	if errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+		return nil, fmt.Errorf("another instance is already listening on %s", l.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.next = next
+	return l, nil
+}
+
+func (l *pipeListener) createInstance(flags uintptr) (syscall.Handle, error) {
+	name, err := syscall.UTF16PtrFromString(l.name)
+	if err != nil {
+		return 0, err
+	}
+	h, _, callErr := createNamedPipe.Call(
+		uintptr(unsafe.Pointer(name)),
+		pipeAccessDuplex|flags,
+		pipeRejectRemoteClients,
+		pipeUnlimitedInstances,
+		4096, 4096, 0, 0,
+	)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return 0, win32Error{call: "CreateNamedPipe", code: callErr.(syscall.Errno)}
+	}
+	return syscall.Handle(h), nil
+}
+
+func (l *pipeListener) accept() (io.ReadWriteCloser, error) {
+	h := l.next
+	ret, _, err := connectNamedPipe.Call(uintptr(h), 0)
+	if errno := err.(syscall.Errno); ret == 0 && errno != errorPipeConnected {
+		syscall.CloseHandle(h)
+		return nil, win32Error{call: "ConnectNamedPipe", code: errno}
+	}
+	if l.closed.Load() {
+		syscall.CloseHandle(h)
+		return nil, errIPCClosed
+	}
+	next, err := l.createInstance(0)
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, err
+	}
+	l.next = next
+	return os.NewFile(uintptr(h), l.name), nil
+}
+
+func (l *pipeListener) close() error {
+	l.closed.Store(true)
+	// Connect to the waiting instance so accept returns.
+	f, err := os.OpenFile(l.name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func dialIPC() (io.ReadWriteCloser, error) {
+	name := ipcPipeName()
+	for {
+		f, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err == nil {
+			return f, nil
+		}
+		// Right after a client connected, there is no waiting instance until
+		// the listener creates the next one.
+		if !errors.Is(err, errorPipeBusy) {
+			return nil, err
+		}
+		p, _ := syscall.UTF16PtrFromString(name)
+		if ret, _, _ := waitNamedPipe.Call(uintptr(unsafe.Pointer(p)), 1000); ret == 0 {
+			return nil, err
+		}
+	}
+}
+
+// ipcMessage is posted to the resident window for each request from another
+// program, the request itself waits in ipcCalls.
+const ipcMessage = w32.WM_APP + 2
+
+type ipcCall struct {
+	req  ipcRequest
+	resp ipcResponse
+	done chan struct{}
+}
+
+// handleIPC is called for requests from the goroutines of the connections.
+// It runs them on the thread of the resident window, which the overlay and
+// the COM objects for the virtual desktops belong to.
+func (r *resident) handleIPC(req ipcRequest) ipcResponse {
+	c := &ipcCall{req: req, done: make(chan struct{})}
+	r.ipcCalls <- c
+	w32.PostMessage(r.window, ipcMessage, 0, 0)
+	<-c.done
+	return c.resp
+}
+
+func (r *resident) runIPCCall() {
+	c := <-r.ipcCalls
+	log.debugf("IPC request %q", c.req.Command)
+	c.resp = dispatchIPC(r, c.req)
+	if !c.resp.OK {
+		log.infof("IPC request %q failed: %s", c.req.Command, c.resp.Error)
+	}
+	close(c.done)
+}
+
+// place puts a window into tiles. Windows moves the window asynchronously,
+// so the result already has the window where it goes.
+func (r *resident) place(p placeRequest) (ipcWindow, error) {
+	defer usePhysicalPixels()()
+
+	window := w32.HWND(p.window)
+	if window == 0 {
+		window = w32.GetForegroundWindow()
+		if window == 0 {
+			return ipcWindow{}, errors.New("there is no active window")
+		}
+	} else if !w32.IsWindow(window) {
+		return ipcWindow{}, fmt.Errorf("there is no window %d", p.window)
+	}
+	from := w32.MonitorFromWindow(window, w32.MONITOR_DEFAULTTONEAREST)
+	var info w32.MONITORINFO
+	if !w32.GetMonitorInfo(from, &info) {
+		return ipcWindow{}, lastError("GetMonitorInfo")
+	}
+	if !isTileableOnAnyDesktop(window, info, r.cfg) {
+		return ipcWindow{}, fmt.Errorf("window %q cannot be tiled", w32.GetWindowText(window))
+	}
+
+	to := from
+	if p.monitor != "" {
+		handles := enumMonitors()
+		var identities []monitorIdentity
+		for _, m := range handles {
+			identities = append(identities, identifyMonitor(m))
+		}
+		i, ok := matchMonitor(p.monitor, identities)
+		if !ok {
+			return ipcWindow{}, fmt.Errorf("there is no monitor %q", p.monitor)
+		}
+		to = handles[i]
+	}
+	cols, rows := p.cols, p.rows
+	if cols == 0 {
+		cols, rows = gridOfMonitor(r.cfg, to)
+	}
+	tiles, err := parseTileRange(p.tiles, cols, rows)
+	if err != nil {
+		return ipcWindow{}, fmt.Errorf("invalid tiles: %w", err)
+	}
+	placed, err := placeOnMonitor(window, from, to, tiles, cols, rows, r.cfg)
+	if err != nil {
+		return ipcWindow{}, err
+	}
+	w := describeWindow(window, identifyMonitor(to).id(), info)
+	w.Bounds = toIPCRect(placed)
+	w.State = normalWindow.String()
+	return w, nil
+}
+
+// listWindows returns the windows that can be tiled, on all virtual desktops.
+func (r *resident) listWindows() ([]ipcWindow, error) {
+	defer usePhysicalPixels()()
+
+	windows := []ipcWindow{}
+	ids := map[w32.HMONITOR]string{}
+	for _, window := range enumWindows() {
+		monitor := w32.MonitorFromWindow(window, w32.MONITOR_DEFAULTTONEAREST)
+		var info w32.MONITORINFO
+		if !w32.GetMonitorInfo(monitor, &info) ||
+			!isTileableOnAnyDesktop(window, info, r.cfg) {
+			continue
+		}
+		if _, ok := ids[monitor]; !ok {
+			ids[monitor] = identifyMonitor(monitor).id()
+		}
+		windows = append(windows, describeWindow(window, ids[monitor], info))
+	}
+	return windows, nil
+}
+
+func describeWindow(window w32.HWND, monitorID string, info w32.MONITORINFO) ipcWindow {
+	class, _ := w32.GetClassName(window)
+	w := ipcWindow{
+		Handle:  uint64(window),
+		App:     windowApp(window),
+		Class:   class,
+		Title:   w32.GetWindowText(window),
+		Desktop: desktops().windowDesktop(uintptr(window)),
+		Monitor: monitorID,
+		State:   queryWindowState(window, info).String(),
+	}
+	if r := w32.GetWindowRect(window); r != nil {
+		w.Bounds = toIPCRect(fromRECT(*r))
+	}
+	return w
+}
+
+func (r *resident) listMonitors() ([]ipcMonitor, error) {
+	defer usePhysicalPixels()()
+	return describeMonitors(r.cfg), nil
+}
+
+func (r *resident) saveWorkspace(name string) (workspace, error) {
+	return saveCurrentWorkspace(r.cfg, name)
+}
+
+func (r *resident) restoreWorkspace(name, desktop string) (int, []string, error) {
+	vd := desktops()
+	d := vd.current()
+	if desktop != "" {
+		var err error
+		d, err = findDesktop(vd, desktop)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	return restoreWorkspace(r.cfg, name, d)
+}
@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// tileLabel is the text shown in the tile in column x and row y. Coordinates
+// start at 1 for users.
+func tileLabel(x, y int) string {
+	return fmt.Sprintf("%d,%d", x+1, y+1)
+}
+
+// readoutText describes the placement in grid units and pixels, e.g.
+// "2×1 of 3×3 — 1706×1392". The pixel size is exactly what the window gets.
+func readoutText(p placement, cols, rows int) string {
+	return fmt.Sprintf(
+		"%d×%d of %d×%d — %d×%d",
+		p.tiles.width(), p.tiles.height(),
+		cols, rows,
+		p.rect.width(), p.rect.height(),
+	)
+}
+
+// readoutBounds is the horizontal band in the middle of the overlay that the
+// readout is drawn in. It is redrawn whenever the readout changes.
+func readoutBounds(width, height, fontSize int) rect {
+	return rect{
+		left:   0,
+		top:    height/2 - fontSize,
+		right:  width,
+		bottom: height/2 + fontSize,
+	}
+}
+
+// labelBounds is where the label of a tile is drawn, in its top-left corner.
+func labelBounds(tile rect, fontSize int) rect {
+	return rect{
+		left:   tile.left + fontSize/2,
+		top:    tile.top + fontSize/4,
+		right:  tile.right,
+		bottom: tile.top + fontSize/4 + fontSize*3/2,
+	}
+}
+
+// fitAspect returns the largest rect with the aspect ratio w:h that fits into
+// area, centered in it. Thumbnails of windows are scaled like this so they
+// are not distorted.
+func fitAspect(area rect, w, h int) rect {
+	if w <= 0 || h <= 0 || area.width() <= 0 || area.height() <= 0 {
+		return rect{left: area.left, top: area.top, right: area.left, bottom: area.top}
+	}
+	fitW, fitH := area.width(), area.width()*h/w
+	if fitH > area.height() {
+		fitW, fitH = area.height()*w/h, area.height()
+	}
+	x := area.left + (area.width()-fitW)/2
+	y := area.top + (area.height()-fitH)/2
+	return rect{left: x, top: y, right: x + fitW, bottom: y + fitH}
+}
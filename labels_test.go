@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestReadoutMatchesPlacement checks that the readout shown while dragging
+// has the size that the window is then moved to.
+func TestReadoutMatchesPlacement(t *testing.T) {
+	// The work area is split into 3x3 tiles of 400x300 pixels.
+	options := dragOptions{width: 1200, height: 900, cols: 3, rows: 3, gap: 8}
+	with := func(f func(*dragOptions)) dragOptions {
+		o := options
+		f(&o)
+		return o
+	}
+	tests := []struct {
+		name     string
+		drag     dragResult
+		options  dragOptions
+		wantText string
+		wantRect rect
+	}{
+		{
+			name:     "single tile",
+			drag:     dragResult{selection: rect{450, 350, 450, 350}},
+			options:  options,
+			wantText: "1×1 of 3×3 — 400×300",
+			wantRect: rect{400, 300, 800, 600},
+		},
+		{
+			name:     "several tiles",
+			drag:     dragResult{selection: rect{10, 10, 500, 650}},
+			options:  options,
+			wantText: "2×3 of 3×3 — 800×900",
+			wantRect: rect{0, 0, 800, 900},
+		},
+		{
+			name:     "full height",
+			drag:     dragResult{action: placeFullHeight, selection: rect{450, 10, 450, 10}},
+			options:  options,
+			wantText: "1×3 of 3×3 — 400×900",
+			wantRect: rect{400, 0, 800, 900},
+		},
+		{
+			name:     "last column takes the rest of the width",
+			drag:     dragResult{selection: rect{900, 10, 900, 10}},
+			options:  with(func(o *dragOptions) { o.width = 1000 }),
+			wantText: "1×1 of 3×3 — 334×300",
+			wantRect: rect{666, 0, 1000, 300},
+		},
+		{
+			name:     "gaps at the work area border",
+			drag:     dragResult{selection: rect{10, 10, 500, 10}, gaps: true},
+			options:  options,
+			wantText: "2×1 of 3×3 — 788×288",
+			wantRect: rect{8, 8, 796, 296},
+		},
+		{
+			name:     "gaps between tiles",
+			drag:     dragResult{selection: rect{450, 350, 450, 350}, gaps: true},
+			options:  options,
+			wantText: "1×1 of 3×3 — 392×292",
+			wantRect: rect{404, 304, 796, 596},
+		},
+		{
+			name: "grows into the next tile for the minimum size",
+			drag: dragResult{selection: rect{450, 350, 450, 350}},
+			options: with(func(o *dragOptions) {
+				o.limits = sizeLimits{minWidth: 500}
+				o.strategy = growIntoTiles
+			}),
+			wantText: "2×1 of 3×3 — 800×300",
+			wantRect: rect{400, 300, 1200, 600},
+		},
+		{
+			name: "grows with gaps",
+			drag: dragResult{selection: rect{450, 350, 450, 350}, gaps: true},
+			options: with(func(o *dragOptions) {
+				o.limits = sizeLimits{minWidth: 500}
+				o.strategy = growIntoTiles
+			}),
+			wantText: "2×1 of 3×3 — 788×292",
+			wantRect: rect{404, 304, 1192, 596},
+		},
+		{
+			name: "gaps are left out if the window would be too small",
+			drag: dragResult{selection: rect{450, 350, 450, 350}, gaps: true},
+			options: with(func(o *dragOptions) {
+				o.limits = sizeLimits{minWidth: 800}
+				o.strategy = growIntoTiles
+			}),
+			wantText: "2×1 of 3×3 — 800×300",
+			wantRect: rect{400, 300, 1200, 600},
+		},
+		{
+			name: "grows by pixels for the minimum size",
+			drag: dragResult{selection: rect{450, 350, 450, 350}},
+			options: with(func(o *dragOptions) {
+				o.limits = sizeLimits{minWidth: 500, minHeight: 400}
+				o.strategy = keepInWorkArea
+			}),
+			wantText: "1×1 of 3×3 — 500×400",
+			wantRect: rect{350, 250, 850, 650},
+		},
+		{
+			name: "move only keeps the window size",
+			drag: dragResult{selection: rect{450, 350, 450, 350}, moveOnly: true},
+			options: with(func(o *dragOptions) {
+				o.windowWidth, o.windowHeight = 500, 200
+			}),
+			wantText: "1×1 of 3×3 — 500×200",
+			wantRect: rect{400, 300, 900, 500},
+		},
+		{
+			name: "move only stays in the work area",
+			drag: dragResult{selection: rect{850, 650, 850, 650}, moveOnly: true},
+			options: with(func(o *dragOptions) {
+				o.windowWidth, o.windowHeight = 500, 400
+			}),
+			wantText: "1×1 of 3×3 — 500×400",
+			wantRect: rect{700, 500, 1200, 900},
+		},
+		{
+			name: "move only shrinks windows larger than the work area",
+			drag: dragResult{selection: rect{450, 350, 450, 350}, moveOnly: true, gaps: true},
+			options: with(func(o *dragOptions) {
+				o.windowWidth, o.windowHeight = 1500, 1000
+			}),
+			wantText: "1×1 of 3×3 — 1200×900",
+			wantRect: rect{0, 0, 1200, 900},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := planDrag(tt.drag, tt.options)
+			if !ok {
+				t.Fatal("the window does not fit")
+			}
+			text := readoutText(p, tt.options.cols, tt.options.rows)
+			if text != tt.wantText {
+				t.Errorf("got readout %q, want %q", text, tt.wantText)
+			}
+			if p.rect != tt.wantRect {
+				t.Errorf("got rect %v, want %v", p.rect, tt.wantRect)
+			}
+			size := fmt.Sprintf("— %d×%d", p.rect.width(), p.rect.height())
+			if !strings.HasSuffix(text, size) {
+				t.Errorf("the readout %q does not end in the window size %q", text, size)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/gonutz/tile_screen/bsp"
+)
+
+// layout is a named grid from the config. Users switch between layouts in the
+// overlay and share them with the export-layout and import-layout commands.
+//
+// Layouts built in the overlay by splitting the work area have a Tree instead
+// of a grid, their Cols and Rows are 0.
+//
+// Desktops limits the layout to these virtual desktops, by number, name or
+// id. Layouts without Desktops are offered on all desktops.
+type layout struct {
+	Name     string    `json:"name"`
+	Cols     int       `json:"cols,omitempty"`
+	Rows     int       `json:"rows,omitempty"`
+	Tree     *bsp.Node `json:"tree,omitempty"`
+	Desktops []string  `json:"desktops,omitempty"`
+}
+
+func (l layout) validate() error {
+	if strings.TrimSpace(l.Name) == "" {
+		return fmt.Errorf("layout has no name")
+	}
+	for _, d := range l.Desktops {
+		if strings.TrimSpace(d) == "" {
+			return fmt.Errorf("layout %q has an empty desktop", l.Name)
+		}
+	}
+	if l.Tree != nil {
+		if err := l.Tree.Validate(); err != nil {
+			return fmt.Errorf("layout %q: %w", l.Name, err)
+		}
+		return nil
+	}
+	if l.Cols < 1 || l.Cols > maxGridSize || l.Rows < 1 || l.Rows > maxGridSize {
+		return fmt.Errorf(
+			"layout %q: grid %dx%d must be between 1x1 and %dx%d",
+			l.Name, l.Cols, l.Rows, maxGridSize, maxGridSize,
+		)
+	}
+	return nil
+}
+
+// availableOn reports whether the layout is offered on the virtual desktop.
+func (l layout) availableOn(vd virtualDesktops, desktop string) bool {
+	if len(l.Desktops) == 0 {
+		return true
+	}
+	for _, key := range l.Desktops {
+		if d, err := findDesktop(vd, key); err == nil && d == desktop {
+			return true
+		}
+	}
+	return false
+}
+
+// zone is a rectangle in normalized coordinates, 0 is the top/left and 1 the
+// bottom/right of the work area.
+type zone struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+const layoutFileVersion = 1
+
+// layoutFile is the JSON format for sharing layouts. It does not depend on
+// any monitor resolution. The zones are redundant for grids, they describe
+// the layout for tools that do not know about grids.
+type layoutFile struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Grid    struct {
+		Cols int `json:"cols"`
+		Rows int `json:"rows"`
+	} `json:"grid"`
+	Zones []zone `json:"zones"`
+}
+
+// gridZones returns the zones of a grid, row by row.
+func gridZones(cols, rows int) []zone {
+	var zones []zone
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			zones = append(zones, zone{
+				X:      float64(x) / float64(cols),
+				Y:      float64(y) / float64(rows),
+				Width:  1 / float64(cols),
+				Height: 1 / float64(rows),
+			})
+		}
+	}
+	return zones
+}
+
+func toBSP(r rect) bsp.Rect {
+	return bsp.Rect{Left: r.left, Top: r.top, Right: r.right, Bottom: r.bottom}
+}
+
+func fromBSP(r bsp.Rect) rect {
+	return rect{left: r.Left, top: r.Top, right: r.Right, bottom: r.Bottom}
+}
+
+func writeLayoutJSON(w io.Writer, l layout) error {
+	var f layoutFile
+	f.Version = layoutFileVersion
+	f.Name = l.Name
+	f.Grid.Cols = l.Cols
+	f.Grid.Rows = l.Rows
+	f.Zones = gridZones(l.Cols, l.Rows)
+	data, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// readLayoutJSON parses and validates a shared layout. If the file has zones,
+// they must be exactly the zones of its grid.
+func readLayoutJSON(r io.Reader) (layout, error) {
+	var f layoutFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return layout{}, fmt.Errorf("invalid layout file: %w", err)
+	}
+	if f.Version != layoutFileVersion {
+		return layout{}, fmt.Errorf(
+			"unsupported layout file version %d, only version %d is supported",
+			f.Version, layoutFileVersion,
+		)
+	}
+	l := layout{Name: f.Name, Cols: f.Grid.Cols, Rows: f.Grid.Rows}
+	if err := l.validate(); err != nil {
+		return layout{}, err
+	}
+	if len(f.Zones) > 0 {
+		if err := matchZones(f.Zones, gridZones(l.Cols, l.Rows)); err != nil {
+			return layout{}, fmt.Errorf("layout %q: %w", l.Name, err)
+		}
+	}
+	return l, nil
+}
+
+// matchZones checks that the zones are the expected ones in any order.
+func matchZones(zones, expected []zone) error {
+	if len(zones) != len(expected) {
+		return fmt.Errorf(
+			"the layout has %d zones but its grid has %d tiles",
+			len(zones), len(expected),
+		)
+	}
+	used := make([]bool, len(expected))
+	for _, z := range zones {
+		found := false
+		for i, e := range expected {
+			if !used[i] && sameZone(z, e) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("zone %+v is not a tile of the grid", z)
+		}
+	}
+	return nil
+}
+
+func sameZone(a, b zone) bool {
+	const epsilon = 1e-6
+	return math.Abs(a.X-b.X) < epsilon && math.Abs(a.Y-b.Y) < epsilon &&
+		math.Abs(a.Width-b.Width) < epsilon && math.Abs(a.Height-b.Height) < epsilon
+}
+
+// writeLayoutSVG draws the layout as it looks on a work area of the given
+// size. The tiles have exactly the pixel sizes that windows get.
+func writeLayoutSVG(w io.Writer, l layout, width, height int, t theme) error {
+	var b strings.Builder
+	fmt.Fprintf(&b,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height,
+	)
+	fmt.Fprintf(&b, "\t<title>%s</title>\n", xmlEscape(l.Name))
+	fmt.Fprintf(&b, "\t<rect width=\"%d\" height=\"%d\" %s/>\n", width, height, svgFill(t.Background))
+	for y := 0; y < l.Rows; y++ {
+		for x := 0; x < l.Cols; x++ {
+			r := tilesToPixels(rect{x, y, x + 1, y + 1}, width, height, l.Cols, l.Rows)
+			fmt.Fprintf(&b,
+				"\t<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"%d\" fill=\"none\" %s/>\n",
+				r.left, r.top, r.width(), r.height(), t.CornerRadius,
+				svgStroke(t.Grid, t.BorderWidth),
+			)
+			fmt.Fprintf(&b,
+				"\t<text x=\"%d\" y=\"%d\" font-family=\"%s\" font-size=\"%d\" text-anchor=\"middle\" dominant-baseline=\"middle\" %s>%s<tspan x=\"%d\" dy=\"1.2em\">%dx%d</tspan></text>\n",
+				r.left+r.width()/2, r.top+r.height()/2,
+				xmlEscape(t.Font), t.FontSize, svgFill(t.Text),
+				tileLabel(x, y),
+				r.left+r.width()/2, r.width(), r.height(),
+			)
+		}
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func svgFill(c color) string {
+	return fmt.Sprintf(`fill="#%02x%02x%02x" fill-opacity="%.3g"`, c.r, c.g, c.b, float64(c.a)/255)
+}
+
+func svgStroke(c color, width int) string {
+	return fmt.Sprintf(
+		`stroke="#%02x%02x%02x" stroke-opacity="%.3g" stroke-width="%d"`,
+		c.r, c.g, c.b, float64(c.a)/255, width,
+	)
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer(
+		"&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;",
+	).Replace(s)
+}
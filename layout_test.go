@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLayoutJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    layout
+		wantErr string
+	}{
+		{
+			name: "grid without zones",
+			file: `{"version": 1, "name": "halves", "grid": {"cols": 2, "rows": 1}}`,
+			want: layout{Name: "halves", Cols: 2, Rows: 1},
+		},
+		{
+			name: "zones in any order",
+			file: `{"version": 1, "name": "halves", "grid": {"cols": 2, "rows": 1}, "zones": [
+				{"x": 0.5, "y": 0, "width": 0.5, "height": 1},
+				{"x": 0, "y": 0, "width": 0.5, "height": 1}
+			]}`,
+			want: layout{Name: "halves", Cols: 2, Rows: 1},
+		},
+		{
+			name: "zones of thirds within rounding",
+			file: `{"version": 1, "name": "thirds", "grid": {"cols": 3, "rows": 1}, "zones": [
+				{"x": 0, "y": 0, "width": 0.3333333, "height": 1},
+				{"x": 0.3333333, "y": 0, "width": 0.3333333, "height": 1},
+				{"x": 0.6666667, "y": 0, "width": 0.3333333, "height": 1}
+			]}`,
+			want: layout{Name: "thirds", Cols: 3, Rows: 1},
+		},
+		{
+			name:    "wrong version",
+			file:    `{"version": 2, "name": "halves", "grid": {"cols": 2, "rows": 1}}`,
+			wantErr: "unsupported layout file version 2",
+		},
+		{
+			name:    "missing version",
+			file:    `{"name": "halves", "grid": {"cols": 2, "rows": 1}}`,
+			wantErr: "unsupported layout file version 0",
+		},
+		{
+			name:    "unknown field",
+			file:    `{"version": 1, "name": "halves", "grid": {"cols": 2, "rows": 1}, "gap": 8}`,
+			wantErr: `unknown field "gap"`,
+		},
+		{
+			name:    "unknown field in the grid",
+			file:    `{"version": 1, "name": "halves", "grid": {"cols": 2, "rows": 1, "gap": 8}}`,
+			wantErr: `unknown field "gap"`,
+		},
+		{
+			name:    "invalid JSON",
+			file:    `{"version": 1,`,
+			wantErr: "invalid layout file",
+		},
+		{
+			name:    "no name",
+			file:    `{"version": 1, "name": " ", "grid": {"cols": 2, "rows": 1}}`,
+			wantErr: "layout has no name",
+		},
+		{
+			name:    "missing grid",
+			file:    `{"version": 1, "name": "none"}`,
+			wantErr: "grid 0x0 must be between 1x1 and 9x9",
+		},
+		{
+			name:    "grid too large",
+			file:    `{"version": 1, "name": "big", "grid": {"cols": 10, "rows": 1}}`,
+			wantErr: "grid 10x1 must be between 1x1 and 9x9",
+		},
+		{
+			name:    "negative grid",
+			file:    `{"version": 1, "name": "bad", "grid": {"cols": 2, "rows": -1}}`,
+			wantErr: "grid 2x-1 must be between 1x1 and 9x9",
+		},
+		{
+			name: "missing zone",
+			file: `{"version": 1, "name": "halves", "grid": {"cols": 2, "rows": 1}, "zones": [
+				{"x": 0, "y": 0, "width": 0.5, "height": 1}
+			]}`,
+			wantErr: "the layout has 1 zones but its grid has 2 tiles",
+		},
+		{
+			name: "zone that is not a tile",
+			file: `{"version": 1, "name": "halves", "grid": {"cols": 2, "rows": 1}, "zones": [
+				{"x": 0, "y": 0, "width": 0.5, "height": 1},
+				{"x": 0.5, "y": 0, "width": 0.5, "height": 0.5}
+			]}`,
+			wantErr: "is not a tile of the grid",
+		},
+		{
+			name: "same zone twice",
+			file: `{"version": 1, "name": "halves", "grid": {"cols": 2, "rows": 1}, "zones": [
+				{"x": 0, "y": 0, "width": 0.5, "height": 1},
+				{"x": 0, "y": 0, "width": 0.5, "height": 1}
+			]}`,
+			wantErr: "is not a tile of the grid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readLayoutJSON(strings.NewReader(tt.file))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutJSONRoundTrip(t *testing.T) {
+	for cols := 1; cols <= maxGridSize; cols++ {
+		for rows := 1; rows <= maxGridSize; rows++ {
+			l := layout{Name: "grid", Cols: cols, Rows: rows}
+			var buf bytes.Buffer
+			if err := writeLayoutJSON(&buf, l); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readLayoutJSON(&buf)
+			if err != nil {
+				t.Fatalf("%dx%d: %v", cols, rows, err)
+			}
+			if !reflect.DeepEqual(got, l) {
+				t.Errorf("%dx%d: got %+v, want %+v", cols, rows, got, l)
+			}
+		}
+	}
+}
+
+func TestWriteLayoutSVG(t *testing.T) {
+	th := builtinThemes["dark"]
+	th.Font = `Fira "Sans" & <Mono>`
+	l := layout{Name: `Tom's <big> & "small"`, Cols: 3, Rows: 2}
+	var buf bytes.Buffer
+	if err := writeLayoutSVG(&buf, l, 1200, 600, th); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+
+	for _, want := range []string{
+		"<title>Tom&apos;s &lt;big&gt; &amp; &quot;small&quot;</title>",
+		`font-family="Fira &quot;Sans&quot; &amp; &lt;Mono&gt;"`,
+		`<rect x="800" y="300" width="400" height="300"`,
+		`>3,2<tspan x="1000" dy="1.2em">400x300</tspan>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("the SVG does not contain %s:\n%s", want, svg)
+		}
+	}
+
+	// The SVG must be well-formed and have the background and one rect per
+	// tile.
+	var title string
+	rects := 0
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, svg)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "rect":
+				rects++
+			case "title":
+				if err := dec.DecodeElement(&title, &start); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if title != l.Name {
+		t.Errorf("got title %q, want %q", title, l.Name)
+	}
+	if want := 1 + l.Cols*l.Rows; rects != want {
+		t.Errorf("got %d rects, want %d", rects, want)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type logLevel int
+
+const (
+	debugLevel logLevel = iota
+	infoLevel
+	warnLevel
+	errorLevel
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case debugLevel:
+		return "DEBUG"
+	case infoLevel:
+		return "INFO"
+	case warnLevel:
+		return "WARN"
+	case errorLevel:
+		return "ERROR"
+	}
+	return "?"
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for l := debugLevel; l <= errorLevel; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+	return infoLevel, fmt.Errorf(
+		`invalid log level %q, use "debug", "info", "warn" or "error"`, s,
+	)
+}
+
+// logger appends lines to a log file. When the file grows beyond maxSize, it
+// is renamed to name.1, the old name.1 to name.2 and so on, keeping at most
+// keep old files.
+//
+// Logging never fails, if the file cannot be written the message is lost.
+type logger struct {
+	mu      sync.Mutex
+	path    string
+	level   logLevel
+	maxSize int64
+	keep    int
+}
+
+var log = &logger{
+	path:    filepath.Join(configDir(), "tile_screen.log"),
+	level:   infoLevel,
+	maxSize: 1 << 20,
+	keep:    3,
+}
+
+func (l *logger) setLevel(level logLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+func (l *logger) debugf(format string, a ...interface{}) {
+	l.write(debugLevel, format, a...)
+}
+
+func (l *logger) infof(format string, a ...interface{}) {
+	l.write(infoLevel, format, a...)
+}
+
+func (l *logger) warnf(format string, a ...interface{}) {
+	l.write(warnLevel, format, a...)
+}
+
+func (l *logger) errorf(format string, a ...interface{}) {
+	l.write(errorLevel, format, a...)
+}
+
+func (l *logger) write(level logLevel, format string, a ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if level < l.level {
+		return
+	}
+	line := fmt.Sprintf(
+		"%s %-5s %s\n",
+		time.Now().Format("2006-01-02 15:04:05.000"),
+		level,
+		fmt.Sprintf(format, a...),
+	)
+
+	os.MkdirAll(filepath.Dir(l.path), 0777)
+	if info, err := os.Stat(l.path); err == nil &&
+		info.Size()+int64(len(line)) > l.maxSize {
+		l.rotate()
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line)
+}
+
+func (l *logger) rotate() {
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if l.keep > 0 {
+		os.Rename(l.path, l.path+".1")
+	} else {
+		os.Remove(l.path)
+	}
+}
@@ -1,10 +1,11 @@
+//go:build windows
+
 package main
 
 import (
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"os"
-	"path/filepath"
 	"runtime"
 	"syscall"
 	"time"
@@ -14,179 +15,127 @@ import (
 )
 
 func main() {
+	defer reportPanics()
 	runtime.LockOSThread()
 
-	var info w32.MONITORINFO
-	var selecting bool
-	var selection w32.RECT
-	tiles := 2
-	window, err := newWindow(
-		0, 0, 1, 1,
-		"tile_screen_window",
-		w32.WS_POPUPWINDOW|w32.WS_VISIBLE,
-		func(window w32.HWND, msg uint32, w, l uintptr) uintptr {
-			switch msg {
-			case w32.WM_MOUSEMOVE:
-				if selecting {
-					x := int32(int16(w32.LOWORD(uint32(l))))
-					y := int32(int16(w32.HIWORD(uint32(l))))
-					old := selection
-					selection.Left = min(selection.Left, x)
-					selection.Top = min(selection.Top, y)
-					selection.Right = max(selection.Right, x)
-					selection.Bottom = max(selection.Bottom, y)
-					if selection != old {
-						w32.InvalidateRect(window, nil, false)
-					}
-				}
-				return 0
-			case w32.WM_LBUTTONDOWN:
-				x := int32(int16(w32.LOWORD(uint32(l))))
-				y := int32(int16(w32.HIWORD(uint32(l))))
-				selecting = true
-				selection = w32.RECT{
-					Left:   x,
-					Top:    y,
-					Right:  x,
-					Bottom: y,
-				}
-				return 0
-			case w32.WM_LBUTTONUP:
-				if selecting {
-					w32.ShowWindow(window, w32.SW_MINIMIZE)
-					w := window
-					const tickDelay = 100 * time.Millisecond
-					for w == window {
-						time.Sleep(tickDelay)
-						w = w32.GetForegroundWindow()
-					}
-					if w == 0 || w == w32.GetDesktopWindow() {
-						win.CloseWindow(window)
-						return 0
-					}
-					m := w32.MonitorFromWindow(w, w32.MONITOR_DEFAULTTONULL)
-					if m == 0 {
-						win.CloseWindow(window)
-						return 0
-					}
-					w32.ShowWindow(w, w32.SW_RESTORE)
-					tileW := int(info.RcWork.Width()) / tiles
-					tileH := int(info.RcWork.Height()) / tiles
-					x := int(selection.Left) / tileW * tileW
-					y := int(selection.Top) / tileH * tileH
-					right := int(selection.Right)/tileW*tileW + tileW
-					if right > int(info.RcWork.Width()) {
-						right = int(info.RcWork.Width())
-					}
-					bottom := int(selection.Bottom)/tileH*tileH + tileH
-					if bottom > int(info.RcWork.Height()) {
-						bottom = int(info.RcWork.Height())
-					}
-					if int(selection.Right)/tileW == tiles-1 {
-						right += int(info.RcWork.Width()) % tileW
-					}
-					if int(selection.Bottom)/tileH == tiles-1 {
-						bottom += int(info.RcWork.Height()) % tileH
-					}
-					w32.SetWindowPos(
-						w, 0,
-						int(info.RcWork.Left)+x, int(info.RcWork.Top)+y,
-						right-x, bottom-y,
-						w32.SWP_ASYNCWINDOWPOS|w32.SWP_NOACTIVATE|w32.SWP_NOOWNERZORDER|w32.SWP_NOZORDER|w32.SWP_SHOWWINDOW,
-					)
-
-					ioutil.WriteFile(settingsPath(), []byte{byte(tiles)}, 0666)
-					win.CloseWindow(window)
-				}
-				return 0
-			case w32.WM_PAINT:
-				const (
-					backColor = w32.COLOR_HIGHLIGHT
-					foreColor = w32.COLOR_BTNFACE
-					inColor   = w32.COLOR_DESKTOP
-				)
-				var ps w32.PAINTSTRUCT
-				hdc := w32.BeginPaint(window, &ps)
-				w32.FillRect(hdc, &w32.RECT{
-					Left:   0,
-					Top:    0,
-					Right:  info.RcWork.Width(),
-					Bottom: info.RcWork.Height(),
-				}, backColor)
-				w := int(info.RcWork.Width()) / tiles
-				h := int(info.RcWork.Height()) / tiles
-				for x := 0; x < tiles; x++ {
-					for y := 0; y < tiles; y++ {
-						r := w32.RECT{
-							Left:   int32(x*w) + 2,
-							Top:    int32(y*h) + 2,
-							Right:  int32((x+1)*w) - 4,
-							Bottom: int32((y+1)*h) - 4,
-						}
-						color := foreColor
-						if overlap(r, selection) {
-							color = inColor
-						}
-						w32.FillRect(hdc, &r, w32.HBRUSH(color))
-					}
-				}
-				w32.EndPaint(window, &ps)
-				return 0
-			case w32.WM_KEYDOWN:
-				if !selecting && '2' <= w && w <= '9' {
-					tiles = int(w - '0')
-					w32.InvalidateRect(window, nil, false)
-				} else if w == w32.VK_ESCAPE {
-					win.CloseWindow(window)
-				}
-				return 0
-			case w32.WM_DESTROY:
-				w32.PostQuitMessage(0)
-				return 0
-			default:
-				return w32.DefWindowProc(window, msg, w, l)
+	if len(os.Args) > 1 {
+		// Commands use COM for the virtual desktops, which has to stay on
+		// the locked thread.
+		console := attachConsole()
+		if err := runCommand(os.Args[1:], os.Stdout); err != nil {
+			// Started from a shortcut or at log-on, e.g. in resident mode,
+			// there is no console to show the error.
+			if !console {
+				fatal(err)
 			}
+			log.errorf("%v", err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-		},
-	)
+	cfg := loadConfigAndLogLevel()
+	o, err := newOverlay(cfg, w32.WS_VISIBLE, 0)
 	if err != nil {
-		panic(err)
+		fatal(err)
 	}
-
-	data, err := ioutil.ReadFile(settingsPath())
-	if err == nil {
-		tiles = int(min(9, max(2, int32(data[0]))))
+	o.onClose = func() { win.CloseWindow(o.window) }
+	o.onDrop = func(d dragResult) {
+		// Place the window that becomes active after hiding the overlay.
+		o.thumb.hide()
+		w32.ShowWindow(o.window, w32.SW_MINIMIZE)
+		w := waitForTarget()
+		if w == 0 || w32.MonitorFromWindow(w, w32.MONITOR_DEFAULTTONULL) == 0 {
+			win.CloseWindow(o.window)
+			return
+		}
+		if !o.place(w, d) {
+			w32.ShowWindow(o.window, w32.SW_RESTORE)
+			return
+		}
+		win.CloseWindow(o.window)
 	}
 
-	w32.ShowWindow(window, w32.SW_MINIMIZE)
-	const tickDelay = 100 * time.Millisecond
-	w := window
-	for w == window {
-		time.Sleep(tickDelay)
-		w = w32.GetForegroundWindow()
+	o.cols, o.rows = loadGridSize()
+
+	w32.ShowWindow(o.window, w32.SW_MINIMIZE)
+	w := waitForTarget()
+	if w == 0 {
+		log.infof("there is no window to place")
+		return
 	}
 	monitor := w32.MonitorFromWindow(w, w32.MONITOR_DEFAULTTONULL)
-	w32.ShowWindow(window, w32.SW_RESTORE)
+	o.setTarget(w)
+	w32.ShowWindow(o.window, w32.SW_RESTORE)
 
 	if monitor == 0 {
-		panic("no monitor under window detected")
+		fatal(errors.New("no monitor under the active window detected"))
 	}
-	if !w32.GetMonitorInfo(monitor, &info) {
-		panic("unable to query monitor info")
+	if err := o.showOn(monitor); err != nil {
+		fatal(err)
 	}
-	w32.SetWindowPos(
-		window, 0,
-		int(info.RcWork.Left), int(info.RcWork.Top),
-		int(info.RcWork.Width()), int(info.RcWork.Height()),
-		w32.SWP_ASYNCWINDOWPOS|w32.SWP_NOACTIVATE|w32.SWP_NOOWNERZORDER|w32.SWP_NOZORDER|w32.SWP_SHOWWINDOW,
-	)
 
 	win.RunMainLoop()
 }
 
+// waitForTarget waits until a window becomes active that the overlay can be
+// used for, e.g. after minimizing the overlay. The overlay itself, tool
+// windows and hidden windows are skipped. It returns 0 if the user activates
+// the desktop or the taskbar instead, or if no window becomes active for a
+// while.
+func waitForTarget() w32.HWND {
+	const (
+		tickDelay = 100 * time.Millisecond
+		timeout   = 10 * time.Second
+	)
+	var skipped w32.HWND
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(tickDelay) {
+		w := w32.GetForegroundWindow()
+		if w == 0 || w == skipped {
+			continue
+		}
+		reason, ok := classifyWindow(queryWindowAttributes(w), asTarget)
+		if ok {
+			return w
+		}
+		if reason == shellWindow {
+			log.debugf("window %q is %s, there is no window to place", w32.GetWindowText(w), reason)
+			return 0
+		}
+		log.debugf("not using window %q as the target, it is %s", w32.GetWindowText(w), reason)
+		skipped = w
+	}
+	log.debugf("no window became active")
+	return 0
+}
+
+// topTarget returns the top-most window in z-order that the overlay can be
+// used for, or 0 if there is none.
+func topTarget() w32.HWND {
+	for _, w := range enumWindows() {
+		if _, ok := classifyWindow(queryWindowAttributes(w), asTarget); ok {
+			return w
+		}
+	}
+	return 0
+}
+
+// loadConfigAndLogLevel loads the config, falling back to the defaults, and
+// applies its log level.
+func loadConfigAndLogLevel() config {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.warnf("using the default config: %v", err)
+	}
+	level, _ := parseLogLevel(cfg.LogLevel)
+	log.setLevel(level)
+	return cfg
+}
+
 type MessageCallback func(window w32.HWND, msg uint32, w, l uintptr) uintptr
 
-func newWindow(x, y, width, height int, className string, style uint, f MessageCallback) (w32.HWND, error) {
+func newWindow(x, y, width, height int, className string, style, exStyle uint, f MessageCallback) (w32.HWND, error) {
 	class := w32.WNDCLASSEX{
 		WndProc:    syscall.NewCallback(f),
 		Cursor:     w32.LoadCursor(0, w32.MakeIntResource(w32.IDC_ARROW)),
@@ -195,10 +144,10 @@ func newWindow(x, y, width, height int, className string, style uint, f MessageC
 	}
 	atom := w32.RegisterClassEx(&class)
 	if atom == 0 {
-		return 0, errors.New("RegisterClassEx failed")
+		return 0, lastError("RegisterClassEx")
 	}
 	window := w32.CreateWindowEx(
-		0,
+		exStyle,
 		syscall.StringToUTF16Ptr(className),
 		nil,
 		style,
@@ -206,30 +155,25 @@ func newWindow(x, y, width, height int, className string, style uint, f MessageC
 		0, 0, 0, nil,
 	)
 	if window == 0 {
-		return 0, errors.New("CreateWindowEx failed")
+		return 0, lastError("CreateWindowEx")
 	}
 	return window, nil
 }
 
-func min(a, b int32) int32 {
-	if a < b {
-		return a
+func fromRECT(r w32.RECT) rect {
+	return rect{
+		left:   int(r.Left),
+		top:    int(r.Top),
+		right:  int(r.Right),
+		bottom: int(r.Bottom),
 	}
-	return b
 }
 
-func max(a, b int32) int32 {
-	if a > b {
-		return a
+func toRECT(r rect) w32.RECT {
+	return w32.RECT{
+		Left:   int32(r.left),
+		Top:    int32(r.top),
+		Right:  int32(r.right),
+		Bottom: int32(r.bottom),
 	}
-	return b
-}
-
-func overlap(a, b w32.RECT) bool {
-	return b.Right >= a.Left && b.Left < a.Right &&
-		b.Bottom >= a.Top && b.Top < a.Bottom
-}
-
-func settingsPath() string {
-	return filepath.Join(os.Getenv("APPDATA"), "screen_tile.set")
 }
@@ -0,0 +1,43 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Outside of Windows there are no windows to tile, only the command line
+// tools work, e.g. to render previews in CI.
+func main() {
+	if err := runCommand(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func residentCommand(args []string) error {
+	return errors.New("resident mode is only available on Windows")
+}
+
+func runTileCommand(c tileCommand, cfg config) error {
+	return errors.New("moving windows is only available on Windows")
+}
+
+func listMonitorsCommand(args []string, stdout io.Writer) error {
+	return errors.New("listing monitors is only available on Windows")
+}
+
+func saveWorkspaceCommand(args []string, stdout io.Writer) error {
+	return errors.New("workspaces are only available on Windows")
+}
+
+func restoreWorkspaceCommand(args []string, stdout io.Writer) error {
+	return errors.New("workspaces are only available on Windows")
+}
+
+func dialIPC() (io.ReadWriteCloser, error) {
+	return nil, errors.New("resident mode is only available on Windows")
+}
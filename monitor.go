@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// rescaleTiles maps tiles of a cols by rows grid to the tiles at the same
+// relative position in a grid of another size. Edges that fall between two
+// tiles of the new grid go outwards so the window keeps at least its relative
+// size, e.g. the right half of a 2x2 grid becomes the right two columns of a
+// 3x3 grid.
+func rescaleTiles(tiles rect, cols, rows, toCols, toRows int) rect {
+	r := rect{
+		left:   tiles.left * toCols / cols,
+		top:    tiles.top * toRows / rows,
+		right:  (tiles.right*toCols + cols - 1) / cols,
+		bottom: (tiles.bottom*toRows + rows - 1) / rows,
+	}
+	r.left = clamp(r.left, 0, toCols-1)
+	r.top = clamp(r.top, 0, toRows-1)
+	r.right = clamp(r.right, r.left+1, toCols)
+	r.bottom = clamp(r.bottom, r.top+1, toRows)
+	return r
+}
+
+// adjacentMonitor returns the index of the monitor that comes step monitors
+// after the current one, cycling around. Monitors are ordered from left to
+// right and then from top to bottom, by the given bounds, so "next" means
+// "to the right" for monitors side by side.
+func adjacentMonitor(bounds []rect, current, step int) int {
+	order := make([]int, len(bounds))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := bounds[order[i]], bounds[order[j]]
+		if a.left != b.left {
+			return a.left < b.left
+		}
+		return a.top < b.top
+	})
+	for i, m := range order {
+		if m == current {
+			n := len(order)
+			return order[((i+step)%n+n)%n]
+		}
+	}
+	return current
+}
+
+// scaleSizeLimits converts a window's size limits from one DPI to another.
+// Windows report their limits for the DPI of the monitor they are on, after
+// moving to a monitor with another DPI they scale them accordingly.
+func scaleSizeLimits(l sizeLimits, fromDPI, toDPI int) sizeLimits {
+	if fromDPI <= 0 || toDPI <= 0 || fromDPI == toDPI {
+		return l
+	}
+	scale := func(x int) int {
+		return (x*toDPI + fromDPI/2) / fromDPI
+	}
+	return sizeLimits{
+		minWidth:  scale(l.minWidth),
+		minHeight: scale(l.minHeight),
+		maxWidth:  scale(l.maxWidth),
+		maxHeight: scale(l.maxHeight),
+	}
+}
+
+// monitorIdentity is what identifies a monitor in the config. Windows gives
+// monitors handles and device names like \\.\DISPLAY1, but these change after
+// rebooting or re-plugging monitors.
+type monitorIdentity struct {
+	// device is the device name like \\.\DISPLAY1.
+	device string
+	// model is the manufacturer and product code from the monitor's EDID,
+	// like DEL4109, instance is the connection that it is on, like UID4353.
+	// Both are empty if Windows does not know them, e.g. for some virtual
+	// monitors.
+	model, instance string
+	bounds          rect
+}
+
+// id is the identifier used in the config and shown by list-monitors. It is
+// "model/instance" if the model is known, otherwise the monitor's position
+// and resolution.
+func (m monitorIdentity) id() string {
+	if m.model == "" {
+		return positionID(m.bounds)
+	}
+	return m.model + "/" + m.instance
+}
+
+// positionID identifies a monitor by where it is and its resolution, like
+// "1920,0 1920x1080".
+func positionID(bounds rect) string {
+	return bounds.String()
+}
+
+// parseDeviceID extracts the model and instance from a monitor's device
+// interface path like
+//
+//	\\?\DISPLAY#DEL4109#5&2d6f0e2&0&UID4353#{e6f07b5f-ee97-4a90-b076-33f57bf4eaa7}
+//
+// The instance is the UID part, which stays the same as long as the monitor
+// is on the same port. Paths of other forms give empty strings.
+func parseDeviceID(path string) (model, instance string) {
+	parts := strings.Split(path, "#")
+	if len(parts) < 3 || parts[1] == "" {
+		return "", ""
+	}
+	model = strings.ToUpper(parts[1])
+	instance = parts[2]
+	if i := strings.LastIndex(instance, "&"); i != -1 {
+		instance = instance[i+1:]
+	}
+	return model, instance
+}
+
+// matchMonitor returns the index of the monitor that the config key refers
+// to. It tries, in this order:
+//
+//	the full id, like DEL4109/UID4353
+//	the device name, like \\.\DISPLAY1, as written by older versions
+//	the model alone, like DEL4109, if only one monitor is of this model,
+//	    so monitors still match after plugging them into another port
+//	the position and resolution, like 1920,0 1920x1080
+func matchMonitor(key string, monitors []monitorIdentity) (int, bool) {
+	for i, m := range monitors {
+		if m.model != "" && strings.EqualFold(key, m.id()) {
+			return i, true
+		}
+	}
+	for i, m := range monitors {
+		if strings.EqualFold(key, m.device) {
+			return i, true
+		}
+	}
+	model := strings.ToUpper(strings.Split(key, "/")[0])
+	found := -1
+	for i, m := range monitors {
+		if m.model != "" && m.model == model {
+			if found != -1 {
+				found = -2
+				break
+			}
+			found = i
+		}
+	}
+	if found >= 0 {
+		return found, true
+	}
+	for i, m := range monitors {
+		if key == positionID(m.bounds) {
+			return i, true
+		}
+	}
+	return 0, false
+}
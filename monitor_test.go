@@ -0,0 +1,225 @@
+package main
+
+import "testing"
+
+func TestRescaleTiles(t *testing.T) {
+	tests := []struct {
+		name           string
+		tiles          rect
+		cols, rows     int
+		toCols, toRows int
+		want           rect
+	}{
+		{
+			name:  "same grid",
+			tiles: rect{1, 0, 3, 2}, cols: 3, rows: 2, toCols: 3, toRows: 2,
+			want: rect{1, 0, 3, 2},
+		},
+		{
+			name:  "right half of 2x2 in 3x3",
+			tiles: rect{1, 0, 2, 2}, cols: 2, rows: 2, toCols: 3, toRows: 3,
+			want: rect{1, 0, 3, 3},
+		},
+		{
+			name:  "center of 3x3 in 2x2",
+			tiles: rect{1, 1, 2, 2}, cols: 3, rows: 3, toCols: 2, toRows: 2,
+			want: rect{0, 0, 2, 2},
+		},
+		{
+			name:  "last column in fewer columns",
+			tiles: rect{3, 0, 4, 1}, cols: 4, rows: 1, toCols: 2, toRows: 1,
+			want: rect{1, 0, 2, 1},
+		},
+		{
+			name:  "edges between tiles go outwards",
+			tiles: rect{2, 0, 3, 1}, cols: 6, rows: 1, toCols: 4, toRows: 1,
+			want: rect{1, 0, 2, 1},
+		},
+		{
+			name:  "to a single tile",
+			tiles: rect{2, 1, 3, 3}, cols: 3, rows: 3, toCols: 1, toRows: 1,
+			want: rect{0, 0, 1, 1},
+		},
+		{
+			name:  "to a finer grid",
+			tiles: rect{0, 0, 1, 1}, cols: 2, rows: 2, toCols: 4, toRows: 6,
+			want: rect{0, 0, 2, 3},
+		},
+		{
+			name:  "outside the grid is clamped",
+			tiles: rect{5, 5, 6, 6}, cols: 3, rows: 3, toCols: 3, toRows: 3,
+			want: rect{2, 2, 3, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rescaleTiles(tt.tiles, tt.cols, tt.rows, tt.toCols, tt.toRows)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdjacentMonitor(t *testing.T) {
+	// The monitors are listed in another order than they are on the desk:
+	// 1 is on the left, 2 in the middle, 0 on the right.
+	sideBySide := []rect{
+		{3840, 0, 5760, 1080},
+		{0, 0, 1920, 1080},
+		{1920, 0, 3840, 1080},
+	}
+	// 0 is on top of 1, 2 is to the right of both.
+	stacked := []rect{
+		{0, -1080, 1920, 0},
+		{0, 0, 1920, 1080},
+		{1920, 0, 3840, 1080},
+	}
+	tests := []struct {
+		name    string
+		bounds  []rect
+		current int
+		step    int
+		want    int
+	}{
+		{name: "next is to the right", bounds: sideBySide, current: 1, step: 1, want: 2},
+		{name: "previous is to the left", bounds: sideBySide, current: 2, step: -1, want: 1},
+		{name: "next wraps around", bounds: sideBySide, current: 0, step: 1, want: 1},
+		{name: "previous wraps around", bounds: sideBySide, current: 1, step: -1, want: 0},
+		{name: "two steps", bounds: sideBySide, current: 2, step: 2, want: 1},
+		{name: "top before bottom", bounds: stacked, current: 0, step: 1, want: 1},
+		{name: "bottom before the next column", bounds: stacked, current: 1, step: 1, want: 2},
+		{name: "single monitor", bounds: sideBySide[:1], current: 0, step: 1, want: 0},
+		{name: "unknown monitor stays", bounds: sideBySide, current: 5, step: 1, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := adjacentMonitor(tt.bounds, tt.current, tt.step)
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleSizeLimits(t *testing.T) {
+	limits := sizeLimits{minWidth: 100, minHeight: 101, maxWidth: 1000, maxHeight: 0}
+	tests := []struct {
+		name           string
+		fromDPI, toDPI int
+		want           sizeLimits
+	}{
+		{
+			name:    "same DPI",
+			fromDPI: 96, toDPI: 96,
+			want: limits,
+		},
+		{
+			name:    "up",
+			fromDPI: 96, toDPI: 144,
+			want: sizeLimits{minWidth: 150, minHeight: 152, maxWidth: 1500, maxHeight: 0},
+		},
+		{
+			name:    "down",
+			fromDPI: 144, toDPI: 96,
+			want: sizeLimits{minWidth: 67, minHeight: 67, maxWidth: 667, maxHeight: 0},
+		},
+		{
+			name:    "unknown DPI",
+			fromDPI: 0, toDPI: 144,
+			want: limits,
+		},
+		{
+			name:    "invalid DPI",
+			fromDPI: 96, toDPI: -1,
+			want: limits,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scaleSizeLimits(limits, tt.fromDPI, tt.toDPI)
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDeviceID(t *testing.T) {
+	tests := []struct {
+		path        string
+		model, inst string
+	}{
+		{
+			path:  `\\?\DISPLAY#DEL4109#5&2d6f0e2&0&UID4353#{e6f07b5f-ee97-4a90-b076-33f57bf4eaa7}`,
+			model: "DEL4109", inst: "UID4353",
+		},
+		{
+			path:  `\\?\DISPLAY#del4109#5&2d6f0e2&0&UID4353#{e6f07b5f-ee97-4a90-b076-33f57bf4eaa7}`,
+			model: "DEL4109", inst: "UID4353",
+		},
+		{
+			path:  `\\?\DISPLAY#LEN40A0#UID0#{e6f07b5f-ee97-4a90-b076-33f57bf4eaa7}`,
+			model: "LEN40A0", inst: "UID0",
+		},
+		{path: `\\?\DISPLAY##UID0#{e6f07b5f}`},
+		{path: `\\.\DISPLAY1`},
+		{path: ""},
+	}
+	for _, tt := range tests {
+		model, inst := parseDeviceID(tt.path)
+		if model != tt.model || inst != tt.inst {
+			t.Errorf("%s: got %q %q, want %q %q", tt.path, model, inst, tt.model, tt.inst)
+		}
+	}
+}
+
+func TestMatchMonitor(t *testing.T) {
+	// Two monitors of the same model on different ports, a laptop screen and
+	// a virtual monitor without a model.
+	monitors := []monitorIdentity{
+		{device: `\\.\DISPLAY1`, model: "DEL4109", instance: "UID4353", bounds: rect{0, 0, 2560, 1440}},
+		{device: `\\.\DISPLAY2`, model: "DEL4109", instance: "UID4354", bounds: rect{2560, 0, 5120, 1440}},
+		{device: `\\.\DISPLAY3`, model: "LEN40A0", instance: "UID0", bounds: rect{-1920, 0, 0, 1080}},
+		{device: `\\.\DISPLAY4`, bounds: rect{0, 1440, 1920, 2520}},
+	}
+	tests := []struct {
+		name   string
+		key    string
+		want   int
+		wantOK bool
+	}{
+		{name: "full id", key: "DEL4109/UID4354", want: 1, wantOK: true},
+		{name: "full id of the same model", key: "DEL4109/UID4353", want: 0, wantOK: true},
+		{name: "full id ignores case", key: "del4109/uid4354", want: 1, wantOK: true},
+		{name: "device name", key: `\\.\DISPLAY2`, want: 1, wantOK: true},
+		{name: "device name ignores case", key: `\\.\display3`, want: 2, wantOK: true},
+		{name: "renamed port of a single model", key: "LEN40A0/UID7", want: 2, wantOK: true},
+		{name: "model of a single monitor", key: "len40a0", want: 2, wantOK: true},
+		{name: "renamed port of duplicate models", key: "DEL4109/UID9999"},
+		{name: "model of duplicate models", key: "DEL4109"},
+		{name: "position of a monitor without a model", key: "0,1440 1920x1080", want: 3, wantOK: true},
+		{name: "position of a monitor with a model", key: "2560,0 2560x1440", want: 1, wantOK: true},
+		{name: "unknown", key: "ACR0001/UID1"},
+		{name: "empty", key: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchMonitor(tt.key, monitors)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %d %v, want %d %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatchMonitorAfterDeviceNamesChanged(t *testing.T) {
+	// After rebooting, Windows numbered the two monitors the other way round.
+	monitors := []monitorIdentity{
+		{device: `\\.\DISPLAY1`, model: "DEL4109", instance: "UID4354"},
+		{device: `\\.\DISPLAY2`, model: "DEL4109", instance: "UID4353"},
+	}
+	if got, ok := matchMonitor("DEL4109/UID4353", monitors); !ok || got != 1 {
+		t.Errorf("got %d %v, want the monitor on port UID4353", got, ok)
+	}
+}
@@ -0,0 +1,656 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gonutz/tile_screen/bsp"
+	"github.com/gonutz/w32"
+)
+
+// overlay is the grid window that the user drags over to select tiles. It is
+// shown once for the active window when the program starts, and whenever a
+// window is dragged by its title bar in resident mode.
+type overlay struct {
+	cfg      config
+	strategy sizeStrategy
+	theme    theme
+
+	window   w32.HWND
+	monitor  w32.HMONITOR
+	info     w32.MONITORINFO
+	drag     dragTracker
+	feedback string
+	buffer   backBuffer
+	thumb    thumbnail
+
+	// target is the window that the preview is for.
+	target       w32.HWND
+	targetLimits sizeLimits
+	targetRect   rect
+
+	cols, rows int
+
+	// windows is the window list for tiling several windows at once, the
+	// handles are in the same order as its entries.
+	windows       windowList
+	windowHandles []w32.HWND
+	order         fillOrder
+
+	// tree is the split layout that replaces the grid, nil while the grid is
+	// shown. selectedLeaf is the region that H, V, M and Enter act on.
+	// resizing is the split whose line is being dragged.
+	tree           *bsp.Node
+	treeName       string
+	selectedLeaf   *bsp.Node
+	resizing       *bsp.Node
+	resizingBounds bsp.Rect
+
+	// passive overlays never take the mouse. In resident mode the user drags
+	// another window and the overlay gets its input from a mouse hook.
+	passive bool
+
+	// onDrop is called when a drag is finished.
+	onDrop func(d dragResult)
+	// onClose is called when the overlay is done without a drag, e.g. when
+	// the user presses Escape.
+	onClose func()
+}
+
+func newOverlay(cfg config, style, exStyle uint) (*overlay, error) {
+	strategy, _ := cfg.sizeStrategy()
+	order, _ := parseFillOrder(cfg.FillOrder)
+	o := &overlay{
+		cfg:      cfg,
+		strategy: strategy,
+		theme:    loadTheme(cfg),
+		drag:     dragTracker{bindings: cfg.Mouse, gapsByDefault: cfg.Gaps},
+		cols:     2,
+		rows:     2,
+		order:    order,
+		onDrop:   func(dragResult) {},
+		onClose:  func() {},
+	}
+	window, err := newWindow(
+		0, 0, 1, 1,
+		"tile_screen_window",
+		w32.WS_POPUPWINDOW|style,
+		w32.WS_EX_LAYERED|w32.WS_EX_TOPMOST|exStyle,
+		o.handleMessage,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the overlay window: %w", err)
+	}
+	o.window = window
+	return o, nil
+}
+
+// setTarget makes the preview show where the window would go.
+func (o *overlay) setTarget(window w32.HWND) {
+	o.thumb.unregister()
+	o.target = window
+	o.targetLimits = windowSizeLimits(window)
+	if r := w32.GetWindowRect(window); r != nil {
+		o.targetRect = fromRECT(*r)
+	}
+	o.thumb.register(o.window, window)
+}
+
+// showOn moves the overlay onto the work area of the monitor and shows it
+// without activating it.
+func (o *overlay) showOn(monitor w32.HMONITOR) error {
+	if !w32.GetMonitorInfo(monitor, &o.info) {
+		return lastError("GetMonitorInfo")
+	}
+	o.monitor = monitor
+	log.debugf("overlay on monitor with work area %v", fromRECT(o.info.RcWork))
+	o.drag.width, o.drag.height = o.workSize()
+	o.feedback = ""
+	w32.SetWindowPos(
+		o.window, 0,
+		int(o.info.RcWork.Left), int(o.info.RcWork.Top),
+		int(o.info.RcWork.Width()), int(o.info.RcWork.Height()),
+		w32.SWP_ASYNCWINDOWPOS|w32.SWP_NOACTIVATE|w32.SWP_NOOWNERZORDER|w32.SWP_NOZORDER|w32.SWP_SHOWWINDOW,
+	)
+	w32.InvalidateRect(o.window, nil, false)
+	return nil
+}
+
+func (o *overlay) hide() {
+	o.thumb.hide()
+	w32.ShowWindow(o.window, w32.SW_HIDE)
+}
+
+func (o *overlay) workSize() (width, height int) {
+	return int(o.info.RcWork.Width()), int(o.info.RcWork.Height())
+}
+
+// dragOptionsFor returns the options for placing a window with the given
+// limits and size.
+func (o *overlay) dragOptionsFor(limits sizeLimits, size rect) dragOptions {
+	width, height := o.workSize()
+	return dragOptions{
+		width:        width,
+		height:       height,
+		cols:         o.cols,
+		rows:         o.rows,
+		limits:       limits,
+		strategy:     o.strategy,
+		gap:          o.cfg.Gap,
+		windowWidth:  size.width(),
+		windowHeight: size.height(),
+	}
+}
+
+// preview returns the current drag and where the target window goes for it.
+// The overlay shows this while dragging.
+func (o *overlay) preview() (dragResult, placement, bool) {
+	d, ok := o.drag.current()
+	if !ok {
+		return dragResult{selection: noSelection}, placement{}, false
+	}
+	p, ok := planDrag(d, o.dragOptionsFor(o.targetLimits, o.targetRect))
+	return d, p, ok
+}
+
+// handleInput passes the event on to the drag and invalidates what it changes
+// on screen. It returns the drag if the event finished it.
+func (o *overlay) handleInput(e inputEvent) (dragResult, bool) {
+	width, height := o.workSize()
+	oldDrag, oldPreview, hadPreview := o.preview()
+	wasActive := o.drag.active()
+	result, done := o.drag.handle(e)
+	newDrag, newPreview, hasPreview := o.preview()
+	// Keep getting mouse messages while dragging, even when the mouse leaves
+	// the overlay, so the drag always ends.
+	if !o.passive && !wasActive && o.drag.active() {
+		w32.SetCapture(o.window)
+	} else if !o.passive && wasActive && !o.drag.active() {
+		w32.ReleaseCapture()
+	}
+	for _, r := range changedTiles(
+		oldDrag.selection, newDrag.selection,
+		width, height, o.cols, o.rows,
+	) {
+		r := toRECT(r)
+		w32.InvalidateRect(o.window, &r, false)
+	}
+	if hasPreview {
+		o.thumb.show(newPreview.rect.inset(o.theme.PreviewWidth))
+	} else {
+		o.thumb.hide()
+	}
+	if oldPreview != newPreview || hadPreview != hasPreview {
+		for _, r := range []rect{
+			oldPreview.rect,
+			newPreview.rect,
+			readoutBounds(width, height, o.theme.FontSize),
+		} {
+			r := toRECT(r)
+			w32.InvalidateRect(o.window, &r, false)
+		}
+	}
+	return result, done
+}
+
+// place moves the window for the finished drag. Ignored windows are left
+// alone. It returns false if the window does not fit and the strategy refuses
+// to place it, the overlay tells the user why then.
+func (o *overlay) place(window w32.HWND, d dragResult) bool {
+	class, _ := w32.GetClassName(window)
+	state := queryWindowState(window, o.info)
+	if ignoreWindow(class, state, o.cfg) {
+		log.infof("leaving %s window of class %q alone", state, class)
+		return true
+	}
+	// The target is usually the window that the overlay came up for. Use the
+	// same limits as for the preview then, so the window ends up where it
+	// showed.
+	limits, size := o.targetLimits, o.targetRect
+	if window != o.target {
+		limits = windowSizeLimits(window)
+		if r := w32.GetWindowRect(window); r != nil {
+			size = fromRECT(*r)
+		}
+	}
+	p, ok := planDrag(d, o.dragOptionsFor(limits, size))
+	if !ok {
+		log.infof(
+			"refusing to place window of class %q, its minimum size is %d x %d",
+			class, limits.minWidth, limits.minHeight,
+		)
+		o.feedback = fmt.Sprintf(
+			"This window cannot be smaller than %d x %d pixels, please select more tiles.",
+			limits.minWidth, limits.minHeight,
+		)
+		w32.InvalidateRect(o.window, nil, false)
+		return false
+	}
+	r := p.rect.offset(int(o.info.RcWork.Left), int(o.info.RcWork.Top))
+	log.infof("placing %s window of class %q at %v", state, class, r)
+	placeWindow(window, r, o.info)
+	if !d.moveOnly {
+		notePlacement(window, o.monitor, p.tiles, o.cols, o.rows)
+	}
+
+	// Passive overlays show the grid of the monitor, the user did not choose
+	// it.
+	if !o.passive {
+		if err := saveGridSize(o.cols, o.rows); err != nil {
+			log.warnf("unable to save the grid size: %v", err)
+		}
+	}
+	return true
+}
+
+// toggleWindowList shows or hides the window list. It is filled with the
+// current windows every time it is shown.
+func (o *overlay) toggleWindowList() {
+	if o.windows.visible {
+		o.windows = windowList{}
+		o.windowHandles = nil
+	} else {
+		o.windowHandles = tileableWindows(o.info, o.cfg, o.window)
+		o.windows = windowList{visible: true, last: -1}
+		for _, w := range o.windowHandles {
+			o.windows.entries = append(o.windows.entries, windowEntry{
+				title:    w32.GetWindowText(w),
+				app:      windowApp(w),
+				selected: w == o.target,
+			})
+		}
+	}
+	w32.InvalidateRect(o.window, nil, false)
+}
+
+// tileSelected distributes the windows selected in the window list over the
+// grid.
+func (o *overlay) tileSelected() {
+	selected := o.windows.selected()
+	if len(selected) == 0 {
+		return
+	}
+	tiles := assignTiles(len(selected), o.cols, o.rows, o.order)
+	for i, j := range selected {
+		w := o.windowHandles[j]
+		title := o.windows.entries[j].title
+		p, ok := placeTiles(tiles[i], o.cfg.Gaps, o.dragOptionsFor(windowSizeLimits(w), rect{}))
+		if !ok {
+			log.infof("not tiling window %q, it does not fit into tiles %v", title, tiles[i])
+			continue
+		}
+		r := p.rect.offset(int(o.info.RcWork.Left), int(o.info.RcWork.Top))
+		log.infof("tiling window %q at %v", title, r)
+		placeWindow(w, r, o.info)
+		notePlacement(w, o.monitor, p.tiles, o.cols, o.rows)
+	}
+	if err := saveGridSize(o.cols, o.rows); err != nil {
+		log.warnf("unable to save the grid size: %v", err)
+	}
+	o.windows = windowList{}
+	o.windowHandles = nil
+	o.onClose()
+}
+
+// useTree replaces the grid with the split layout, or shows the grid again
+// for a nil tree.
+func (o *overlay) useTree(tree *bsp.Node, name string) {
+	o.tree = tree
+	o.treeName = name
+	o.selectedLeaf = nil
+	o.resizing = nil
+	if tree != nil {
+		width, height := o.workSize()
+		o.selectedLeaf = tree.Leaves(toBSP(rect{right: width, bottom: height}))[0].Node
+	}
+	w32.InvalidateRect(o.window, nil, false)
+}
+
+// handleTreeInput lets the user select regions of the split layout and drag
+// split lines to resize them.
+func (o *overlay) handleTreeInput(e inputEvent) {
+	width, height := o.workSize()
+	bounds := toBSP(rect{right: width, bottom: height})
+	switch e.kind {
+	case mouseDown:
+		if e.button != leftButton {
+			return
+		}
+		tolerance := o.theme.BorderWidth + 6
+		if n, r, ok := o.tree.SplitAt(bounds, e.x, e.y, tolerance); ok {
+			o.resizing, o.resizingBounds = n, r
+			w32.SetCapture(o.window)
+		} else if leaf, ok := o.tree.LeafAt(bounds, e.x, e.y); ok {
+			o.selectedLeaf = leaf.Node
+		}
+	case mouseMove:
+		if o.resizing == nil {
+			return
+		}
+		o.resizing.Resize(o.resizing.RatioAt(o.resizingBounds, e.x, e.y))
+	case mouseUp, cancelDrag:
+		if o.resizing == nil {
+			return
+		}
+		o.resizing = nil
+		w32.ReleaseCapture()
+	}
+	w32.InvalidateRect(o.window, nil, false)
+}
+
+// splitSelected splits the selected region in two halves.
+func (o *overlay) splitSelected(orientation bsp.Orientation) {
+	if o.selectedLeaf == nil {
+		return
+	}
+	if err := o.selectedLeaf.SplitLeaf(orientation, 0.5); err != nil {
+		log.warnf("unable to split the region: %v", err)
+		return
+	}
+	o.selectedLeaf = o.selectedLeaf.First
+	w32.InvalidateRect(o.window, nil, false)
+}
+
+// mergeSelected merges the selected region with its sibling.
+func (o *overlay) mergeSelected() {
+	if o.selectedLeaf == nil {
+		return
+	}
+	if parent := o.tree.Parent(o.selectedLeaf); parent != nil {
+		parent.Merge()
+		o.selectedLeaf = parent
+		w32.InvalidateRect(o.window, nil, false)
+	}
+}
+
+// saveTree adds the split layout to the config so it can be used again.
+func (o *overlay) saveTree() {
+	name := o.treeName
+	if name == "" {
+		n := 1
+		for _, l := range o.cfg.Layouts {
+			if l.Tree != nil {
+				n++
+			}
+		}
+		name = fmt.Sprintf("split layout %d", n)
+	}
+	l := layout{Name: name, Tree: o.tree.Copy()}
+	// Keep the desktops when saving a layout again.
+	if old, ok := o.cfg.findLayout(name); ok {
+		l.Desktops = old.Desktops
+	}
+	if err := installLayout(l); err != nil {
+		log.errorf("unable to save layout %q: %v", name, err)
+		o.feedback = "Unable to save the layout: " + err.Error()
+	} else {
+		replaced := false
+		for i := range o.cfg.Layouts {
+			if o.cfg.Layouts[i].Name == name {
+				o.cfg.Layouts[i] = l
+				replaced = true
+			}
+		}
+		if !replaced {
+			o.cfg.Layouts = append(o.cfg.Layouts, l)
+		}
+		o.treeName = name
+		o.feedback = fmt.Sprintf("Saved layout %q", name)
+	}
+	w32.InvalidateRect(o.window, nil, false)
+}
+
+// nextLayout cycles through the layouts of the current virtual desktop,
+// starting after the one that is shown.
+func (o *overlay) nextLayout() {
+	vd := desktops()
+	layouts := o.cfg.layoutsOn(vd, vd.current())
+	if len(layouts) == 0 {
+		return
+	}
+	next := 0
+	for i, l := range layouts {
+		isGrid := l.Tree == nil && o.tree == nil && l.Cols == o.cols && l.Rows == o.rows
+		isTree := l.Tree != nil && o.tree != nil && l.Name == o.treeName
+		if isGrid || isTree {
+			next = (i + 1) % len(layouts)
+		}
+	}
+	l := layouts[next]
+	if l.Tree != nil {
+		o.useTree(l.Tree.Copy(), l.Name)
+	} else {
+		o.useTree(nil, "")
+		o.cols, o.rows = l.Cols, l.Rows
+	}
+	o.feedback = l.Name
+	w32.InvalidateRect(o.window, nil, false)
+}
+
+// placeInLeaf moves the target window into the selected region.
+func (o *overlay) placeInLeaf() {
+	width, height := o.workSize()
+	index := 0
+	for i, leaf := range o.tree.Leaves(toBSP(rect{right: width, bottom: height})) {
+		if leaf.Node == o.selectedLeaf {
+			index = i
+		}
+	}
+	r := leafOnMonitor(o.tree, index, o.info, o.targetLimits, o.cfg)
+	class, _ := w32.GetClassName(o.target)
+	state := queryWindowState(o.target, o.info)
+	if ignoreWindow(class, state, o.cfg) {
+		log.infof("leaving %s window of class %q alone", state, class)
+	} else {
+		log.infof("placing %s window of class %q at %v", state, class, r)
+		placeWindow(o.target, r, o.info)
+		noteLeafPlacement(o.target, o.monitor, o.tree, index)
+	}
+	o.onClose()
+}
+
+func (o *overlay) handleMessage(window w32.HWND, msg uint32, w, l uintptr) uintptr {
+	switch msg {
+	case w32.WM_MOUSEMOVE:
+		if o.tree != nil {
+			o.handleTreeInput(mouseEvent(mouseMove, 0, w, l))
+			return 0
+		}
+		o.handleInput(mouseEvent(mouseMove, 0, w, l))
+		return 0
+	case w32.WM_LBUTTONDOWN, w32.WM_RBUTTONDOWN, w32.WM_MBUTTONDOWN:
+		if o.feedback != "" {
+			o.feedback = ""
+			w32.InvalidateRect(window, nil, false)
+		}
+		e := mouseEvent(mouseDown, buttonOf(msg), w, l)
+		if o.tree != nil {
+			o.handleTreeInput(e)
+			return 0
+		}
+		if o.windows.visible && !o.drag.active() {
+			width, height := o.workSize()
+			list := windowListBounds(width, height, len(o.windows.entries), o.theme.FontSize)
+			if i, ok := windowEntryAt(list, len(o.windows.entries), o.theme.FontSize, e.x, e.y); ok {
+				o.windows.click(i, e.mods&ctrlKey != 0)
+				r := toRECT(list)
+				w32.InvalidateRect(window, &r, false)
+				return 0
+			}
+		}
+		o.handleInput(e)
+		return 0
+	case w32.WM_LBUTTONUP, w32.WM_RBUTTONUP, w32.WM_MBUTTONUP:
+		if o.tree != nil {
+			o.handleTreeInput(mouseEvent(mouseUp, buttonOf(msg), w, l))
+			return 0
+		}
+		if d, done := o.handleInput(mouseEvent(mouseUp, buttonOf(msg), w, l)); done {
+			o.onDrop(d)
+		}
+		return 0
+	case w32.WM_CAPTURECHANGED:
+		// Some other window took the mouse, e.g. a system dialog.
+		if w32.HWND(l) != window && o.resizing != nil {
+			o.handleTreeInput(inputEvent{kind: cancelDrag})
+		}
+		if w32.HWND(l) != window && o.drag.active() {
+			o.handleInput(inputEvent{kind: cancelDrag})
+		}
+		return 0
+	case w32.WM_PAINT:
+		// The window shows what was last passed to UpdateLayeredWindow, so
+		// the whole overlay is drawn again for every paint.
+		var ps w32.PAINTSTRUCT
+		w32.BeginPaint(window, &ps)
+		width, height := o.workSize()
+		c := o.buffer.begin(width, height)
+		d, p, hasPreview := o.preview()
+		drawOverlay(c, overlayView{
+			width:        width,
+			height:       height,
+			cols:         o.cols,
+			rows:         o.rows,
+			selection:    d.selection,
+			preview:      p,
+			hasPreview:   hasPreview,
+			feedback:     o.feedback,
+			windows:      o.windows,
+			tree:         o.tree,
+			selectedLeaf: o.selectedLeaf,
+			theme:        o.theme,
+		})
+		if err := o.buffer.end(window, o.cfg.overlayAlpha()); err != nil {
+			log.warnf("unable to draw the overlay: %v", err)
+		}
+		w32.EndPaint(window, &ps)
+		return 0
+	case w32.WM_SETTINGCHANGE, w32.WM_SYSCOLORCHANGE:
+		// Follow the system when dark mode or high contrast change.
+		o.theme = loadTheme(o.cfg)
+		w32.InvalidateRect(window, nil, false)
+		return w32.DefWindowProc(window, msg, w, l)
+	case w32.WM_KEYDOWN, w32.WM_KEYUP:
+		if w == w32.VK_CONTROL || w == w32.VK_SHIFT {
+			// Update the preview right away when a modifier changes, without
+			// waiting for the mouse to move.
+			if o.drag.active() {
+				o.handleInput(inputEvent{
+					kind: mouseMove,
+					x:    o.drag.lastX,
+					y:    o.drag.lastY,
+					mods: currentModifiers(),
+				})
+			}
+			return 0
+		}
+		if msg == w32.WM_KEYUP {
+			return 0
+		}
+		if !o.drag.active() && '2' <= w && w <= '9' {
+			o.useTree(nil, "")
+			o.cols = int(w - '0')
+			o.rows = o.cols
+			w32.InvalidateRect(window, nil, false)
+		} else if !o.drag.active() && w == 'L' {
+			o.nextLayout()
+		} else if !o.drag.active() && w == 'B' {
+			// Switch between the grid and splitting the work area.
+			if o.tree == nil {
+				o.useTree(bsp.NewLeaf(), "")
+			} else {
+				o.useTree(nil, "")
+			}
+		} else if o.tree != nil && w == 'H' {
+			o.splitSelected(bsp.LeftRight)
+		} else if o.tree != nil && w == 'V' {
+			o.splitSelected(bsp.TopBottom)
+		} else if o.tree != nil && w == 'M' {
+			o.mergeSelected()
+		} else if o.tree != nil && w == 'S' {
+			o.saveTree()
+		} else if !o.drag.active() && w == 'W' {
+			o.toggleWindowList()
+		} else if o.windows.visible && w == 'A' {
+			// Select all windows of the application clicked last, or of
+			// the target window.
+			app := windowApp(o.target)
+			if o.windows.last >= 0 {
+				app = o.windows.entries[o.windows.last].app
+			}
+			o.windows.selectApp(app)
+			w32.InvalidateRect(window, nil, false)
+		} else if !o.drag.active() && w == 'O' {
+			o.order = (o.order + 1) % fillOrder(len(fillOrderNames))
+			o.feedback = "Fill order: " + o.order.String()
+			w32.InvalidateRect(window, nil, false)
+		} else if o.windows.visible && w == w32.VK_RETURN {
+			o.tileSelected()
+		} else if o.tree != nil && w == w32.VK_RETURN {
+			o.placeInLeaf()
+		} else if w == w32.VK_ESCAPE && o.resizing != nil {
+			o.handleTreeInput(inputEvent{kind: cancelDrag})
+		} else if w == w32.VK_ESCAPE && o.drag.active() {
+			// Only cancel the drag, the user can start a new one.
+			o.handleInput(inputEvent{kind: cancelDrag})
+		} else if w == w32.VK_ESCAPE {
+			o.onClose()
+		}
+		return 0
+	case w32.WM_ERASEBKGND:
+		// WM_PAINT covers everything, erasing first would flicker.
+		return 1
+	case w32.WM_DESTROY:
+		o.buffer.free()
+		o.thumb.unregister()
+		w32.PostQuitMessage(0)
+		return 0
+	default:
+		return w32.DefWindowProc(window, msg, w, l)
+	}
+}
+
+// mouseEvent turns the parameters of a mouse message into an input event.
+func mouseEvent(kind inputKind, button mouseButton, w, l uintptr) inputEvent {
+	var mods modifiers
+	if w&w32.MK_CONTROL != 0 {
+		mods |= ctrlKey
+	}
+	if w&w32.MK_SHIFT != 0 {
+		mods |= shiftKey
+	}
+	return inputEvent{
+		kind:   kind,
+		button: button,
+		x:      int(int16(w32.LOWORD(uint32(l)))),
+		y:      int(int16(w32.HIWORD(uint32(l)))),
+		mods:   mods,
+	}
+}
+
+func buttonOf(msg uint32) mouseButton {
+	switch msg {
+	case w32.WM_RBUTTONDOWN, w32.WM_RBUTTONUP:
+		return rightButton
+	case w32.WM_MBUTTONDOWN, w32.WM_MBUTTONUP:
+		return middleButton
+	}
+	return leftButton
+}
+
+// currentModifiers returns the modifier keys that are held down right now.
+func currentModifiers() modifiers {
+	var mods modifiers
+	for _, key := range []struct {
+		vk  int
+		mod modifiers
+	}{
+		{w32.VK_CONTROL, ctrlKey},
+		{w32.VK_SHIFT, shiftKey},
+		{w32.VK_MENU, altKey},
+	} {
+		if w32.GetAsyncKeyState(key.vk)&0x8000 != 0 {
+			mods |= key.mod
+		}
+	}
+	return mods
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	imagecolor "image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// TestRenderPreview compares previews with the images in testdata/preview.
+// Run "go test -run RenderPreview -update" after intended changes to the
+// drawing and check the new images.
+func TestRenderPreview(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		cols, rows    int
+		theme         string
+		selection     string
+	}{
+		{name: "2x2-light", width: 320, height: 180, cols: 2, rows: 2, theme: "light"},
+		{name: "3x2-dark-selected", width: 480, height: 270, cols: 3, rows: 2, theme: "dark", selection: "1,1-2,1"},
+		{name: "4x3-high-contrast-selected", width: 400, height: 300, cols: 4, rows: 3, theme: "high-contrast", selection: "4,3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th, err := resolveTheme(tt.theme, nil, false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var selected *rect
+			if tt.selection != "" {
+				tiles, err := parseTileRange(tt.selection, tt.cols, tt.rows)
+				if err != nil {
+					t.Fatal(err)
+				}
+				selected = &tiles
+			}
+			img := renderPreview(tt.width, tt.height, tt.cols, tt.rows, th, selected)
+
+			path := filepath.Join("testdata", "preview", tt.name+".png")
+			if *updateGolden {
+				if err := writePNG(path, img); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want := readGolden(t, path)
+			if img.Bounds() != want.Bounds() {
+				t.Fatalf("the image is %v, want %v", img.Bounds(), want.Bounds())
+			}
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					if g, w := img.At(x, y), want.At(x, y); !sameColor(g, w) {
+						t.Fatalf("pixel %d,%d is %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func readGolden(t *testing.T, path string) image.Image {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// sameColor compares colors the way they are stored in PNG files, which are
+// not premultiplied by alpha like image.RGBA.
+func sameColor(a, b imagecolor.Color) bool {
+	return imagecolor.NRGBAModel.Convert(a) == imagecolor.NRGBAModel.Convert(b)
+}
+
+func TestPreviewCommandLimits(t *testing.T) {
+	for _, args := range [][]string{
+		{"-grid", "10x2"},
+		{"-grid", "2x12"},
+		{"-size", "100000x100000"},
+		{"-size", "1920x9000"},
+	} {
+		out := filepath.Join(t.TempDir(), "preview.png")
+		err := previewCommand(append(args, "-o", out), &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "must be at most") {
+			t.Errorf("%v: got error %v, want a limit error", args, err)
+		}
+		if _, err := os.Stat(out); err == nil {
+			t.Errorf("%v: wrote an image", args)
+		}
+	}
+}
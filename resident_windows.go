@@ -0,0 +1,404 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"sort"
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32"
+	"github.com/gonutz/win"
+)
+
+// resident snaps windows that the user drags by their title bar while holding
+// the snap modifier. Windows tells us when a window starts and stops moving,
+// in between a low-level mouse hook follows the mouse over the overlay.
+type resident struct {
+	cfg config
+	// window is hidden, it receives the hotkeys.
+	window  w32.HWND
+	hotkeys map[uintptr]tileCommand
+	overlay *overlay
+	snapKey modifiers
+	// dragged is the window being moved with the snap key held, 0 if there
+	// is none.
+	dragged   w32.HWND
+	monitor   w32.HMONITOR
+	mouseHook w32.HHOOK
+	// auto is nil unless auto-tiling is on.
+	auto *autoTiler
+	// placements are the tiles of the windows placed in resident mode, they
+	// are placed again when the monitors change. displays are the monitors
+	// that they refer to.
+	placements map[w32.HWND]tilePlacement
+	displays   []display
+	// ipcCalls are the requests from other programs, see handleIPC.
+	ipcCalls chan *ipcCall
+	// layout is the layout picked last from the tray menu.
+	layout string
+	// interactive is set while the overlay is shown from the tray menu or
+	// for a show-overlay request, see showOverlay.
+	interactive bool
+}
+
+// passiveOverlayStyle lets the mouse through the overlay while a window is
+// being snapped, and keeps it from taking the focus.
+const passiveOverlayStyle = w32.WS_EX_TRANSPARENT | w32.WS_EX_NOACTIVATE
+
+// displayChangeTimer delays re-placing windows after the monitors changed.
+// Windows moves windows off of removed monitors by itself, this has to be
+// done first.
+const (
+	displayChangeTimer = 1
+	displayChangeDelay = 1000 // ms
+)
+
+// theResident is used by the hook callbacks, which cannot carry any state.
+var theResident *resident
+
+var (
+	winEventCallback  = syscall.NewCallback(handleWinEvent)
+	mouseHookCallback = syscall.NewCallback(handleMouseHook)
+)
+
+func residentCommand(args []string) error {
+	flags := flag.NewFlagSet("resident", flag.ContinueOnError)
+	trayFlag := flags.Bool("tray", true, "show an icon with a menu in the notification area")
+	ipcFlag := flags.Bool("ipc", true, `take commands from other programs, see "tile_screen control -h"`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	runtime.LockOSThread()
+	cfg := loadConfigAndLogLevel()
+	snapKey, _ := parseModifier(cfg.SnapModifier)
+	o, err := newOverlay(cfg, 0, passiveOverlayStyle|w32.WS_EX_TOOLWINDOW)
+	if err != nil {
+		return err
+	}
+	o.passive = true
+	// There is only the mouse button that drags the window. It selects the
+	// tile under the mouse, like in the overlay it would select a range.
+	o.drag.bindings.Left = "place-tile"
+	r := &resident{
+		cfg:        cfg,
+		overlay:    o,
+		snapKey:    snapKey,
+		placements: map[w32.HWND]tilePlacement{},
+		ipcCalls:   make(chan *ipcCall, 16),
+	}
+	theResident = r
+	o.onClose = r.closeOverlay
+	o.onDrop = func(d dragResult) {
+		// Drops of passive overlays come from endDrag.
+		if r.interactive && o.place(o.target, d) {
+			r.closeOverlay()
+		}
+	}
+	r.displays, _ = currentDisplays()
+	placementListener = func(window w32.HWND, p tilePlacement) {
+		r.placements[window] = p
+		if r.auto != nil {
+			// The other windows fill the space that it left.
+			r.auto.manual[uintptr(window)] = true
+			r.auto.scheduleReflow()
+		}
+	}
+	r.window, err = newWindow(
+		0, 0, 0, 0,
+		"tile_screen_resident",
+		w32.WS_POPUP, w32.WS_EX_TOOLWINDOW,
+		r.handleMessage,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to create the resident window: %w", err)
+	}
+	r.registerHotkeys()
+	if *trayFlag {
+		if err := r.addTrayIcon(); err != nil {
+			log.warnf("%v", err)
+		}
+		defer r.removeTrayIcon()
+	}
+	if *ipcFlag {
+		l, err := listenIPC()
+		if err != nil {
+			log.warnf("unable to take commands from other programs: %v", err)
+		} else {
+			defer l.close()
+			go serveIPC(l, r.handleIPC)
+		}
+	}
+
+	// The auto-tiling events are always hooked since the tray menu can turn
+	// auto-tiling on at any time.
+	events := append(
+		[][2]uint32{{eventSystemMoveSizeStart, eventSystemMoveSizeEnd}},
+		autoTileEvents...,
+	)
+	if cfg.AutoTile != "" {
+		r.auto = newAutoTiler(cfg)
+	}
+	for _, e := range events {
+		hook, err := setWinEventHook(e[0], e[1], winEventCallback)
+		if err != nil {
+			return err
+		}
+		defer unhookWinEvent(hook)
+	}
+	if r.auto != nil {
+		log.infof("auto-tiling with the %s layout", r.auto.layout)
+		r.auto.reflow()
+	}
+
+	log.infof("running in resident mode, hold %s while dragging a window to snap it", cfg.SnapModifier)
+	win.RunMainLoop()
+	return nil
+}
+
+func handleWinEvent(hook uintptr, event uint32, window w32.HWND, object, child, thread, time uintptr) uintptr {
+	if int32(object) != objidWindow || int32(child) != childidSelf || window == 0 {
+		return 0
+	}
+	r := theResident
+	switch event {
+	case eventSystemMoveSizeStart:
+		if r.dragged == 0 && currentModifiers()&r.snapKey != 0 {
+			// The event also comes when resizing the window by its borders,
+			// only moving it snaps.
+			x, y, _ := w32.GetCursorPos()
+			if !isSizingBorder(window, x, y) {
+				r.startDrag(window)
+			}
+		}
+	case eventSystemMoveSizeEnd:
+		if window == r.dragged {
+			r.endDrag()
+		} else {
+			// The user moved the window out of its tiles.
+			delete(r.placements, window)
+			if r.auto != nil {
+				// The window joins the layout again, maybe on another
+				// monitor.
+				delete(r.auto.manual, uintptr(window))
+				r.auto.scheduleReflow()
+			}
+		}
+	default:
+		if r.auto != nil {
+			r.auto.scheduleReflow()
+		}
+	}
+	return 0
+}
+
+func handleMouseHook(code int32, w uintptr, info *msllHookStruct) uintptr {
+	r := theResident
+	if code >= 0 && w == w32.WM_MOUSEMOVE && r.dragged != 0 {
+		r.moveTo(int(info.Pt.X), int(info.Pt.Y))
+	}
+	return uintptr(w32.CallNextHookEx(
+		r.mouseHook, int(code),
+		w32.WPARAM(w), w32.LPARAM(unsafe.Pointer(info)),
+	))
+}
+
+func (r *resident) startDrag(window w32.HWND) {
+	if r.interactive {
+		r.closeOverlay()
+	}
+	if reason, ok := classifyWindow(queryWindowAttributes(window), asTarget); !ok {
+		log.debugf("not snapping window %q, it is %s", w32.GetWindowText(window), reason)
+		return
+	}
+	hook, err := setWindowsHook(w32.WH_MOUSE_LL, mouseHookCallback)
+	if err != nil {
+		log.errorf("unable to follow the mouse: %v", err)
+		return
+	}
+	r.mouseHook = hook
+	r.dragged = window
+	r.overlay.setTarget(window)
+	r.monitor = 0
+	x, y, _ := w32.GetCursorPos()
+	r.moveTo(x, y)
+}
+
+// moveTo updates the selection for the mouse at the given screen position.
+// The overlay follows the mouse to other monitors and shows their grids.
+func (r *resident) moveTo(x, y int) {
+	o := r.overlay
+	monitor := w32.MonitorFromPoint(x, y, w32.MONITOR_DEFAULTTONEAREST)
+	if monitor != r.monitor {
+		o.handleInput(inputEvent{kind: cancelDrag})
+		o.cols, o.rows = gridOfMonitor(r.cfg, monitor)
+		if err := o.showOn(monitor); err != nil {
+			log.warnf("unable to show the overlay: %v", err)
+			return
+		}
+		r.monitor = monitor
+		o.handleInput(r.event(mouseDown, x, y))
+	}
+	o.handleInput(r.event(mouseMove, x, y))
+}
+
+func (r *resident) endDrag() {
+	w32.UnhookWindowsHookEx(r.mouseHook)
+	window := r.dragged
+	r.dragged = 0
+	x, y, _ := w32.GetCursorPos()
+	d, done := r.overlay.handleInput(r.event(mouseUp, x, y))
+	r.overlay.hide()
+	if done {
+		r.overlay.place(window, d)
+	}
+}
+
+// event returns an event for the mouse at the given screen position. The
+// modifiers are left out, the snap key is held and should not change the
+// drag.
+func (r *resident) event(kind inputKind, x, y int) inputEvent {
+	work := r.overlay.info.RcWork
+	return inputEvent{
+		kind:   kind,
+		button: leftButton,
+		x:      x - int(work.Left),
+		y:      y - int(work.Top),
+	}
+}
+
+// registerHotkeys registers the hotkeys from the config. Hotkeys that other
+// programs already use are left out.
+func (r *resident) registerHotkeys() {
+	var keys []string
+	for key, command := range r.cfg.Hotkeys {
+		if command != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	r.hotkeys = map[uintptr]tileCommand{}
+	for i, key := range keys {
+		h, _ := parseHotkey(key)
+		c, _ := parseTileCommand(r.cfg.Hotkeys[key])
+		id := uintptr(i + 1)
+		ret, _, _ := registerHotKey.Call(
+			uintptr(r.window), id,
+			uintptr(hotkeyModifiers(h.mods)), uintptr(virtualKey(h.key)),
+		)
+		if ret == 0 {
+			log.warnf("unable to register hotkey %s: %v", key, lastError("RegisterHotKey"))
+			continue
+		}
+		r.hotkeys[id] = c
+	}
+}
+
+func (r *resident) handleMessage(window w32.HWND, msg uint32, w, l uintptr) uintptr {
+	switch msg {
+	case w32.WM_HOTKEY:
+		if c, ok := r.hotkeys[w]; ok {
+			if err := runTileCommand(c, r.cfg); err != nil {
+				log.infof("%s: %v", c, err)
+			}
+		}
+		return 0
+	case trayMessage:
+		r.handleTrayMessage(l)
+		return 0
+	case ipcMessage:
+		r.runIPCCall()
+		return 0
+	case w32.WM_DISPLAYCHANGE:
+		w32.SetTimer(window, displayChangeTimer, displayChangeDelay, 0)
+		return 0
+	case w32.WM_TIMER:
+		if w == displayChangeTimer {
+			killTimer.Call(uintptr(window), displayChangeTimer)
+			r.displaysChanged()
+		}
+		return 0
+	default:
+		if msg == taskbarCreatedMessage && taskbarCreatedMessage != 0 {
+			if err := r.addTrayIcon(); err != nil {
+				log.warnf("%v", err)
+			}
+		}
+		return w32.DefWindowProc(window, msg, w, l)
+	}
+}
+
+// displaysChanged puts the windows that were placed in resident mode back
+// into their tiles after monitors were added, removed or changed their
+// resolution. Windows on monitors that are gone go to the same tiles on the
+// monitor closest to where their monitor was.
+func (r *resident) displaysChanged() {
+	defer usePhysicalPixels()()
+
+	displays, handles := currentDisplays()
+	change := diffDisplays(r.displays, displays)
+	before := r.displays
+	r.displays = displays
+	if change.empty() {
+		return
+	}
+	log.infof(
+		"monitors changed, removed: %v, added: %v, changed: %v",
+		change.removed, change.added, change.changed,
+	)
+	if r.auto != nil {
+		// Auto-tiling lays out the windows again, except for the ones that
+		// the user placed by hand, they go back into their tiles below.
+		defer r.auto.scheduleReflow()
+	}
+
+	identities := identifyMonitors()
+	gridFor := func(id string) (cols, rows int) {
+		for _, m := range identities {
+			if m.id() == id {
+				return r.cfg.monitorGrid(m, identities)
+			}
+		}
+		return loadGridSize()
+	}
+	for window, p := range r.placements {
+		if !w32.IsWindow(window) {
+			delete(r.placements, window)
+			continue
+		}
+		if r.auto != nil && !r.auto.manual[uintptr(window)] {
+			continue
+		}
+		to, ok := remapPlacement(p, before, displays, change, gridFor)
+		if !ok {
+			continue
+		}
+		var monitor w32.HMONITOR
+		for i, d := range displays {
+			if d.id == to.monitor {
+				monitor = handles[i]
+			}
+		}
+		var info w32.MONITORINFO
+		if !w32.GetMonitorInfo(monitor, &info) ||
+			queryWindowState(window, info) == minimizedWindow {
+			continue
+		}
+		limits := windowSizeLimits(window)
+		var placed rect
+		if to.tree != nil {
+			placed = leafOnMonitor(to.tree, to.leaf, info, limits, r.cfg)
+		} else {
+			placed, ok = tilesOnMonitor(to.tiles, info, to.cols, to.rows, limits, r.cfg)
+			if !ok {
+				log.infof("not moving window %q, it does not fit into tiles %v", w32.GetWindowText(window), to.tiles)
+				continue
+			}
+		}
+		log.infof("putting window %q back into %v at %v", w32.GetWindowText(window), to, placed)
+		placeWindow(window, placed, info)
+		r.placements[window] = to
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// maxGridSize is the largest number of columns or rows in a grid.
+const maxGridSize = 9
+
+func settingsPath() string {
+	dir := os.Getenv("APPDATA")
+	if dir == "" {
+		dir = configDir()
+	}
+	return filepath.Join(dir, "screen_tile.set")
+}
+
+// loadGridSize returns the grid size that was used last, 2x2 at first.
+func loadGridSize() (cols, rows int) {
+	cols, rows = 2, 2
+	data, err := ioutil.ReadFile(settingsPath())
+	if err == nil && len(data) > 0 {
+		// Older versions only stored one byte for square grids.
+		cols = min(maxGridSize, max(1, int(data[0])))
+		rows = cols
+		if len(data) > 1 {
+			rows = min(maxGridSize, max(1, int(data[1])))
+		}
+	}
+	return
+}
+
+func saveGridSize(cols, rows int) error {
+	if err := os.MkdirAll(filepath.Dir(settingsPath()), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(settingsPath(), []byte{byte(cols), byte(rows)}, 0666)
+}
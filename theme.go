@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// color is an RGBA color. Alpha blends the color over what is below it in the
+// overlay, 255 is opaque. Everything in the overlay is made more see-through
+// by the overlayOpacity setting.
+type color struct {
+	r, g, b, a uint8
+}
+
+// parseColor parses colors in the forms "#RRGGBB" and "#RRGGBBAA".
+func parseColor(s string) (color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 || !strings.HasPrefix(s, "#") {
+		return color{}, fmt.Errorf("invalid color %q, use #RRGGBB or #RRGGBBAA", s)
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color{}, fmt.Errorf("invalid color %q, use #RRGGBB or #RRGGBBAA", s)
+	}
+	return color{r: uint8(n >> 24), g: uint8(n >> 16), b: uint8(n >> 8), a: uint8(n)}, nil
+}
+
+func (c color) String() string {
+	if c.a == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.r, c.g, c.b, c.a)
+}
+
+func (c color) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *color) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := parseColor(s)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+func rgba(r, g, b, a uint8) color {
+	return color{r: r, g: g, b: b, a: a}
+}
+
+// theme describes what the overlay looks like. Sizes are in pixels.
+type theme struct {
+	// Base names the theme that a custom theme starts from, only the fields
+	// set in the config are changed. It is ignored for built-in themes.
+	Base string `json:"base,omitempty"`
+
+	// Background is behind the readout, the window list and messages. The
+	// rest of the overlay has no background, only the grid and the selection
+	// are drawn over the desktop.
+	Background color `json:"background"`
+	Grid       color `json:"grid"`
+	Selection  color `json:"selection"`
+	Preview    color `json:"preview"`
+	Text       color `json:"text"`
+
+	// BorderWidth is the width of the grid lines around each tile.
+	BorderWidth int `json:"borderWidth"`
+	// CornerRadius rounds the tiles, the selection and the preview outline.
+	CornerRadius int `json:"cornerRadius"`
+	// SelectionOutline is drawn around selected tiles if its width is not 0.
+	SelectionOutline      color `json:"selectionOutline"`
+	SelectionOutlineWidth int   `json:"selectionOutlineWidth"`
+	// PreviewWidth is the line width of the outline that shows where the
+	// window will go.
+	PreviewWidth int `json:"previewWidth"`
+
+	Font     string `json:"font"`
+	FontSize int    `json:"fontSize"`
+}
+
+var builtinThemes = map[string]theme{
+	"light": {
+		Background:            rgba(0xf3, 0xf3, 0xf3, 0xff),
+		Grid:                  rgba(0x80, 0x80, 0x80, 0xff),
+		Selection:             rgba(0x00, 0x78, 0xd4, 0x80),
+		SelectionOutline:      rgba(0x00, 0x5a, 0x9e, 0xff),
+		SelectionOutlineWidth: 2,
+		Preview:               rgba(0x00, 0x00, 0x00, 0xff),
+		Text:                  rgba(0x00, 0x00, 0x00, 0xff),
+		BorderWidth:           1,
+		CornerRadius:          6,
+		PreviewWidth:          3,
+		Font:                  "Segoe UI",
+		FontSize:              20,
+	},
+	"dark": {
+		Background:            rgba(0x10, 0x10, 0x10, 0xff),
+		Grid:                  rgba(0xa0, 0xa0, 0xa0, 0xff),
+		Selection:             rgba(0x00, 0x78, 0xd7, 0xa0),
+		SelectionOutline:      rgba(0x4c, 0xc2, 0xff, 0xff),
+		SelectionOutlineWidth: 2,
+		Preview:               rgba(0xff, 0xff, 0xff, 0xff),
+		Text:                  rgba(0xff, 0xff, 0xff, 0xff),
+		BorderWidth:           1,
+		CornerRadius:          6,
+		PreviewWidth:          3,
+		Font:                  "Segoe UI",
+		FontSize:              20,
+	},
+	"high-contrast": {
+		Background:            rgba(0x00, 0x00, 0x00, 0xff),
+		Grid:                  rgba(0xff, 0xff, 0xff, 0xff),
+		Selection:             rgba(0x1a, 0xeb, 0xff, 0xff),
+		SelectionOutline:      rgba(0xff, 0xff, 0x00, 0xff),
+		SelectionOutlineWidth: 4,
+		Preview:               rgba(0xff, 0xff, 0x00, 0xff),
+		Text:                  rgba(0xff, 0xff, 0xff, 0xff),
+		BorderWidth:           2,
+		CornerRadius:          0,
+		PreviewWidth:          5,
+		Font:                  "Segoe UI",
+		FontSize:              24,
+	},
+}
+
+// autoThemeName picks the built-in theme that matches the system settings.
+func autoThemeName(darkMode, highContrast bool) string {
+	if highContrast {
+		return "high-contrast"
+	}
+	if darkMode {
+		return "dark"
+	}
+	return "light"
+}
+
+// resolveTheme returns the theme of the given name. The name "auto" follows
+// the system's dark mode and high contrast settings. Custom themes from the
+// config take precedence over built-in themes of the same name.
+func resolveTheme(
+	name string,
+	custom map[string]json.RawMessage,
+	darkMode, highContrast bool,
+) (theme, error) {
+	return resolveThemeDepth(name, custom, darkMode, highContrast, 0)
+}
+
+func resolveThemeDepth(
+	name string,
+	custom map[string]json.RawMessage,
+	darkMode, highContrast bool,
+	depth int,
+) (theme, error) {
+	if depth > 10 {
+		return theme{}, fmt.Errorf("theme %q: too many levels of base themes", name)
+	}
+	if name == "" || name == "auto" {
+		name = autoThemeName(darkMode, highContrast)
+	}
+	if data, ok := custom[name]; ok {
+		var base struct {
+			Base string `json:"base"`
+		}
+		if err := json.Unmarshal(data, &base); err != nil {
+			return theme{}, fmt.Errorf("theme %q: %w", name, err)
+		}
+		if base.Base == name {
+			return theme{}, fmt.Errorf("theme %q uses itself as base", name)
+		}
+		t, err := resolveThemeDepth(base.Base, custom, darkMode, highContrast, depth+1)
+		if err != nil {
+			return theme{}, err
+		}
+		if err := json.Unmarshal(data, &t); err != nil {
+			return theme{}, fmt.Errorf("theme %q: %w", name, err)
+		}
+		t.Base = ""
+		return t, nil
+	}
+	if t, ok := builtinThemes[name]; ok {
+		return t, nil
+	}
+	return theme{}, fmt.Errorf(
+		`unknown theme %q, use "auto", "light", "dark", "high-contrast" or a theme from the config`,
+		name,
+	)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResolveTheme(t *testing.T) {
+	withDark := func(f func(*theme)) theme {
+		t := builtinThemes["dark"]
+		f(&t)
+		return t
+	}
+	tests := []struct {
+		name         string
+		theme        string
+		custom       string
+		darkMode     bool
+		highContrast bool
+		want         theme
+		wantErr      string
+	}{
+		{
+			name:  "built-in theme",
+			theme: "dark",
+			want:  builtinThemes["dark"],
+		},
+		{
+			name:  "auto in light mode",
+			theme: "auto",
+			want:  builtinThemes["light"],
+		},
+		{
+			name:     "auto in dark mode",
+			theme:    "auto",
+			darkMode: true,
+			want:     builtinThemes["dark"],
+		},
+		{
+			name:         "auto prefers high contrast over dark mode",
+			theme:        "auto",
+			darkMode:     true,
+			highContrast: true,
+			want:         builtinThemes["high-contrast"],
+		},
+		{
+			name:     "empty name is auto",
+			darkMode: true,
+			want:     builtinThemes["dark"],
+		},
+		{
+			name:   "custom theme changes only the given fields of its base",
+			theme:  "mine",
+			custom: `{"mine": {"base": "dark", "selection": "#ff000080", "fontSize": 30}}`,
+			want: withDark(func(t *theme) {
+				t.Selection = rgba(0xff, 0, 0, 0x80)
+				t.FontSize = 30
+			}),
+		},
+		{
+			name:  "custom theme inherits through several bases",
+			theme: "a",
+			custom: `{
+				"a": {"base": "b", "borderWidth": 3},
+				"b": {"base": "dark", "borderWidth": 2, "cornerRadius": 0}
+			}`,
+			want: withDark(func(t *theme) {
+				t.BorderWidth = 3
+				t.CornerRadius = 0
+			}),
+		},
+		{
+			name:     "custom theme without base starts from auto",
+			theme:    "mine",
+			custom:   `{"mine": {"previewWidth": 9}}`,
+			darkMode: true,
+			want:     withDark(func(t *theme) { t.PreviewWidth = 9 }),
+		},
+		{
+			name:   "custom theme replaces a built-in theme of the same name",
+			theme:  "dark",
+			custom: `{"dark": {"base": "light"}}`,
+			want:   builtinThemes["light"],
+		},
+		{
+			name:    "theme that names itself as base",
+			theme:   "mine",
+			custom:  `{"mine": {"base": "mine"}}`,
+			wantErr: `theme "mine" uses itself as base`,
+		},
+		{
+			name:    "cycle of base themes",
+			theme:   "a",
+			custom:  `{"a": {"base": "b"}, "b": {"base": "a"}}`,
+			wantErr: "too many levels of base themes",
+		},
+		{
+			name:    "unknown theme",
+			theme:   "nope",
+			wantErr: `unknown theme "nope"`,
+		},
+		{
+			name:    "unknown base theme",
+			theme:   "mine",
+			custom:  `{"mine": {"base": "nope"}}`,
+			wantErr: `unknown theme "nope"`,
+		},
+		{
+			name:    "invalid color",
+			theme:   "mine",
+			custom:  `{"mine": {"base": "dark", "grid": "red"}}`,
+			wantErr: `theme "mine": invalid color "red"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var custom map[string]json.RawMessage
+			if tt.custom != "" {
+				if err := json.Unmarshal([]byte(tt.custom), &custom); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := resolveTheme(tt.theme, custom, tt.darkMode, tt.highContrast)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
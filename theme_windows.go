@@ -0,0 +1,51 @@
+package main
+
+import (
+	"unsafe"
+
+	"github.com/gonutz/w32"
+)
+
+const (
+	spiGetHighContrast = 0x0042
+	hcfHighContrastOn  = 0x00000001
+)
+
+type highContrast struct {
+	Size          uint32
+	Flags         uint32
+	DefaultScheme *uint16
+}
+
+// systemTheme reports whether apps should use dark mode and whether high
+// contrast is turned on.
+func systemTheme() (darkMode, highContrastOn bool) {
+	light, errno := w32.RegGetUint32(
+		w32.HKEY_CURRENT_USER,
+		`Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`,
+		"AppsUseLightTheme",
+	)
+	darkMode = errno == 0 && light == 0
+
+	hc := highContrast{Size: uint32(unsafe.Sizeof(highContrast{}))}
+	ret, _, _ := systemParametersInfo.Call(
+		spiGetHighContrast,
+		uintptr(hc.Size),
+		uintptr(unsafe.Pointer(&hc)),
+		0,
+	)
+	highContrastOn = ret != 0 && hc.Flags&hcfHighContrastOn != 0
+	return
+}
+
+// loadTheme resolves the configured theme for the current system settings.
+// Errors were already reported when loading the config, so they fall back to
+// the matching built-in theme here.
+func loadTheme(c config) theme {
+	darkMode, highContrastOn := systemTheme()
+	t, err := resolveTheme(c.Theme, c.Themes, darkMode, highContrastOn)
+	if err != nil {
+		t, _ = resolveTheme("auto", nil, darkMode, highContrastOn)
+	}
+	return t
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"unsafe"
+
+	"github.com/gonutz/w32"
+)
+
+// thumbnail is a live DWM thumbnail of one window shown inside another. If
+// DWM composition is not available, it silently does nothing and the overlay
+// shows only the plain selection.
+type thumbnail struct {
+	id         w32.HTHUMBNAIL
+	registered bool
+	visible    bool
+	dest       rect
+}
+
+type dwmThumbnailProperties struct {
+	Flags                uint32
+	Destination          w32.RECT
+	Source               w32.RECT
+	Opacity              byte
+	Visible              w32.BOOL
+	SourceClientAreaOnly w32.BOOL
+}
+
+// register starts showing the source window in the destination window. The
+// thumbnail stays hidden until show is called.
+func (t *thumbnail) register(dest, source w32.HWND) {
+	if t.registered || source == 0 {
+		return
+	}
+	var enabled w32.BOOL
+	if w32.DwmIsCompositionEnabled(&enabled) != w32.S_OK || enabled == 0 {
+		log.debugf("DWM composition is disabled, not showing thumbnails")
+		return
+	}
+	if hr := w32.DwmRegisterThumbnail(dest, source, &t.id); hr != w32.S_OK {
+		log.debugf("DwmRegisterThumbnail failed with HRESULT 0x%08X", uint32(hr))
+		return
+	}
+	t.registered = true
+}
+
+// show scales the thumbnail into the area, keeping its aspect ratio, and makes
+// it visible.
+func (t *thumbnail) show(area rect) {
+	if !t.registered {
+		return
+	}
+	var size w32.SIZE
+	if w32.DwmQueryThumbnailSourceSize(t.id, &size) != w32.S_OK {
+		return
+	}
+	dest := fitAspect(area, int(size.CX), int(size.CY))
+	if t.visible && dest == t.dest {
+		return
+	}
+	t.visible = true
+	t.dest = dest
+	t.update(dwmThumbnailProperties{
+		Flags:       w32.DWM_TNP_RECTDESTINATION | w32.DWM_TNP_VISIBLE | w32.DWM_TNP_OPACITY,
+		Destination: toRECT(dest),
+		Opacity:     255,
+		Visible:     1,
+	})
+}
+
+func (t *thumbnail) hide() {
+	if !t.registered || !t.visible {
+		return
+	}
+	t.visible = false
+	t.update(dwmThumbnailProperties{Flags: w32.DWM_TNP_VISIBLE})
+}
+
+func (t *thumbnail) update(p dwmThumbnailProperties) {
+	dwmUpdateThumbnailProperties.Call(uintptr(t.id), uintptr(unsafe.Pointer(&p)))
+}
+
+func (t *thumbnail) unregister() {
+	if t.registered {
+		w32.DwmUnregisterThumbnail(t.id)
+		*t = thumbnail{}
+	}
+}
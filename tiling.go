@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fillOrder is how several windows are distributed over the grid at once.
+type fillOrder int
+
+const (
+	// fillRows puts the windows into the tiles from left to right, row by
+	// row.
+	fillRows fillOrder = iota
+	// fillColumns puts the windows into the tiles from top to bottom, column
+	// by column.
+	fillColumns
+	// fillLargestFirst gives the first window half of the grid, the next one
+	// half of the rest and so on, splitting along the longer side. Each
+	// window gets less than half if the rest would not have a tile for every
+	// other window otherwise.
+	fillLargestFirst
+)
+
+var fillOrderNames = []string{"rows", "columns", "largest-first"}
+
+func (f fillOrder) String() string {
+	if 0 <= int(f) && int(f) < len(fillOrderNames) {
+		return fillOrderNames[f]
+	}
+	return fmt.Sprintf("fillOrder(%d)", int(f))
+}
+
+func parseFillOrder(s string) (fillOrder, error) {
+	for i, name := range fillOrderNames {
+		if strings.ToLower(s) == name {
+			return fillOrder(i), nil
+		}
+	}
+	return 0, fmt.Errorf(
+		`invalid fill order %q, use "rows", "columns" or "largest-first"`,
+		s,
+	)
+}
+
+// assignTiles returns the tiles for n windows in a grid of cols by rows, in
+// tile units. Together the tiles cover the whole grid. If there are more
+// windows than tiles, the extra windows share tiles with the first ones.
+func assignTiles(n, cols, rows int, order fillOrder) []rect {
+	switch order {
+	case fillColumns:
+		tiles := assignRows(n, rows, cols)
+		for i, t := range tiles {
+			tiles[i] = rect{left: t.top, top: t.left, right: t.bottom, bottom: t.right}
+		}
+		return tiles
+	case fillLargestFirst:
+		return assignLargestFirst(n, cols, rows)
+	}
+	return assignRows(n, cols, rows)
+}
+
+// assignRows gives each window a tile, row by row. The last window grows to
+// the right edge and the windows in the last row grow to the bottom so no
+// tiles stay empty.
+func assignRows(n, cols, rows int) []rect {
+	tiles := make([]rect, n)
+	count := cols * rows
+	used := min(n, count)
+	lastRow := (used - 1) / cols
+	for i := range tiles {
+		j := i % count
+		x, y := j%cols, j/cols
+		t := rect{left: x, top: y, right: x + 1, bottom: y + 1}
+		if j == used-1 {
+			t.right = cols
+		}
+		if y == lastRow {
+			t.bottom = rows
+		}
+		tiles[i] = t
+	}
+	return tiles
+}
+
+func assignLargestFirst(n, cols, rows int) []rect {
+	tiles := make([]rect, n)
+	rest := rect{right: cols, bottom: rows}
+	for i := range tiles {
+		if i == n-1 {
+			tiles[i] = rest
+			break
+		}
+		// The window takes columns or rows of the rest, the ones after it
+		// need at least a tile each.
+		w, h := rest.width(), rest.height()
+		spare := w*h - (n - i - 1)
+		colsTaken := min((w+1)/2, spare/h)
+		rowsTaken := min((h+1)/2, spare/w)
+		switch {
+		case colsTaken > 0 && (w >= h || rowsTaken == 0):
+			tiles[i] = rect{left: rest.left, top: rest.top, right: rest.left + colsTaken, bottom: rest.bottom}
+			rest.left += colsTaken
+		case rowsTaken > 0:
+			tiles[i] = rect{left: rest.left, top: rest.top, right: rest.right, bottom: rest.top + rowsTaken}
+			rest.top += rowsTaken
+		default:
+			// There are not enough tiles left to split, the remaining
+			// windows fill them row by row.
+			for j, t := range assignRows(n-i, w, h) {
+				tiles[i+j] = t.offset(rest.left, rest.top)
+			}
+			return tiles
+		}
+	}
+	return tiles
+}
+
+// windowEntry is a window in the overlay's window list.
+type windowEntry struct {
+	title string
+	// app is the name of the window's executable, e.g. "notepad.exe".
+	app      string
+	selected bool
+}
+
+// windowList is the list of windows that the overlay shows for tiling several
+// windows at once.
+type windowList struct {
+	visible bool
+	entries []windowEntry
+	// last is the entry clicked last, -1 if there is none.
+	last int
+}
+
+// click selects only the entry, or toggles it with ctrl held.
+func (l *windowList) click(i int, ctrl bool) {
+	if i < 0 || i >= len(l.entries) {
+		return
+	}
+	if ctrl {
+		l.entries[i].selected = !l.entries[i].selected
+	} else {
+		for j := range l.entries {
+			l.entries[j].selected = j == i
+		}
+	}
+	l.last = i
+}
+
+// selectApp selects all windows of the application.
+func (l *windowList) selectApp(app string) {
+	for i := range l.entries {
+		if l.entries[i].app == app {
+			l.entries[i].selected = true
+		}
+	}
+}
+
+// selected returns the indices of all selected entries, in list order.
+func (l *windowList) selected() []int {
+	var s []int
+	for i, e := range l.entries {
+		if e.selected {
+			s = append(s, i)
+		}
+	}
+	return s
+}
+
+// windowListBounds returns where the list of n windows goes in the overlay,
+// on the right side of the work area.
+func windowListBounds(width, height, n, fontSize int) rect {
+	lineH := fontSize * 3 / 2
+	w := width / 3
+	h := min(n*lineH+fontSize, height-2*fontSize)
+	return rect{
+		left:   width - w - fontSize,
+		top:    fontSize,
+		right:  width - fontSize,
+		bottom: fontSize + h,
+	}
+}
+
+// windowEntryBounds returns the line of entry i in the list.
+func windowEntryBounds(list rect, i, fontSize int) rect {
+	lineH := fontSize * 3 / 2
+	top := list.top + fontSize/2 + i*lineH
+	return rect{left: list.left, top: top, right: list.right, bottom: top + lineH}
+}
+
+// windowEntryAt returns the entry under the point, if any.
+func windowEntryAt(list rect, n, fontSize, x, y int) (int, bool) {
+	for i := 0; i < n; i++ {
+		r := windowEntryBounds(list, i, fontSize)
+		if r.bottom > list.bottom {
+			break
+		}
+		if r.left <= x && x < r.right && r.top <= y && y < r.bottom {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// windowEntryText is what the list shows for an entry, cut to a length that
+// fits into the list.
+func windowEntryText(e windowEntry) string {
+	const maxLen = 48
+	text := e.title
+	if e.app != "" {
+		text += " — " + e.app
+	}
+	if r := []rune(text); len(r) > maxLen {
+		text = string(r[:maxLen-3]) + "..."
+	}
+	return text
+}
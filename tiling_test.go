@@ -0,0 +1,221 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssignTiles(t *testing.T) {
+	// The grid has 3x2 tiles.
+	const cols, rows = 3, 2
+	tests := []struct {
+		name  string
+		n     int
+		order fillOrder
+		want  []rect
+	}{
+		{
+			name:  "rows, fewer windows than tiles",
+			n:     2,
+			order: fillRows,
+			want:  []rect{{0, 0, 1, 2}, {1, 0, 3, 2}},
+		},
+		{
+			name:  "rows, last row not full",
+			n:     4,
+			order: fillRows,
+			want:  []rect{{0, 0, 1, 1}, {1, 0, 2, 1}, {2, 0, 3, 1}, {0, 1, 3, 2}},
+		},
+		{
+			name:  "rows, a window per tile",
+			n:     6,
+			order: fillRows,
+			want: []rect{
+				{0, 0, 1, 1}, {1, 0, 2, 1}, {2, 0, 3, 1},
+				{0, 1, 1, 2}, {1, 1, 2, 2}, {2, 1, 3, 2},
+			},
+		},
+		{
+			name:  "rows, more windows than tiles",
+			n:     8,
+			order: fillRows,
+			want: []rect{
+				{0, 0, 1, 1}, {1, 0, 2, 1}, {2, 0, 3, 1},
+				{0, 1, 1, 2}, {1, 1, 2, 2}, {2, 1, 3, 2},
+				{0, 0, 1, 1}, {1, 0, 2, 1},
+			},
+		},
+		{
+			name:  "columns, fewer windows than tiles",
+			n:     3,
+			order: fillColumns,
+			want:  []rect{{0, 0, 1, 1}, {0, 1, 1, 2}, {1, 0, 3, 2}},
+		},
+		{
+			name:  "columns, a window per tile",
+			n:     6,
+			order: fillColumns,
+			want: []rect{
+				{0, 0, 1, 1}, {0, 1, 1, 2},
+				{1, 0, 2, 1}, {1, 1, 2, 2},
+				{2, 0, 3, 1}, {2, 1, 3, 2},
+			},
+		},
+		{
+			name:  "columns, more windows than tiles",
+			n:     7,
+			order: fillColumns,
+			want: []rect{
+				{0, 0, 1, 1}, {0, 1, 1, 2},
+				{1, 0, 2, 1}, {1, 1, 2, 2},
+				{2, 0, 3, 1}, {2, 1, 3, 2},
+				{0, 0, 1, 1},
+			},
+		},
+		{
+			name:  "largest first, one window",
+			n:     1,
+			order: fillLargestFirst,
+			want:  []rect{{0, 0, 3, 2}},
+		},
+		{
+			name:  "largest first, halves",
+			n:     3,
+			order: fillLargestFirst,
+			want:  []rect{{0, 0, 2, 2}, {2, 0, 3, 1}, {2, 1, 3, 2}},
+		},
+		{
+			name:  "largest first leaves a tile for every other window",
+			n:     4,
+			order: fillLargestFirst,
+			want:  []rect{{0, 0, 1, 2}, {1, 0, 2, 2}, {2, 0, 3, 1}, {2, 1, 3, 2}},
+		},
+		{
+			name:  "largest first, a window per tile",
+			n:     6,
+			order: fillLargestFirst,
+			want: []rect{
+				{0, 0, 1, 1}, {1, 0, 2, 1}, {2, 0, 3, 1},
+				{0, 1, 1, 2}, {1, 1, 2, 2}, {2, 1, 3, 2},
+			},
+		},
+		{
+			name:  "largest first, more windows than tiles",
+			n:     8,
+			order: fillLargestFirst,
+			want: []rect{
+				{0, 0, 1, 1}, {1, 0, 2, 1}, {2, 0, 3, 1},
+				{0, 1, 1, 2}, {1, 1, 2, 2}, {2, 1, 3, 2},
+				{0, 0, 1, 1}, {1, 0, 2, 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assignTiles(tt.n, cols, rows, tt.order)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAssignTilesCoversGrid checks for every grid and number of windows up to
+// the tile count that the windows do not overlap and leave no tile empty.
+func TestAssignTilesCoversGrid(t *testing.T) {
+	for _, order := range []fillOrder{fillRows, fillColumns, fillLargestFirst} {
+		for cols := 1; cols <= 4; cols++ {
+			for rows := 1; rows <= 4; rows++ {
+				for n := 1; n <= cols*rows; n++ {
+					var owner [4][4]int
+					for i, tile := range assignTiles(n, cols, rows, order) {
+						if tile.width() < 1 || tile.height() < 1 {
+							t.Errorf("%s %dx%d, %d windows: window %d has tiles %v", order, cols, rows, n, i+1, tile)
+						}
+						for x := tile.left; x < tile.right; x++ {
+							for y := tile.top; y < tile.bottom; y++ {
+								if owner[x][y] != 0 {
+									t.Errorf("%s %dx%d, %d windows: windows %d and %d share tile %d,%d", order, cols, rows, n, owner[x][y], i+1, x+1, y+1)
+								}
+								owner[x][y] = i + 1
+							}
+						}
+					}
+					for x := 0; x < cols; x++ {
+						for y := 0; y < rows; y++ {
+							if owner[x][y] == 0 {
+								t.Errorf("%s %dx%d, %d windows: tile %d,%d is empty", order, cols, rows, n, x+1, y+1)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWindowListClick(t *testing.T) {
+	entries := func() []windowEntry {
+		return []windowEntry{
+			{title: "main.go", app: "code.exe"},
+			{title: "Inbox", app: "thunderbird.exe", selected: true},
+			{title: "notes.md", app: "code.exe"},
+		}
+	}
+	type click struct {
+		i    int
+		ctrl bool
+	}
+	tests := []struct {
+		name     string
+		clicks   []click
+		want     []int
+		wantLast int
+	}{
+		{name: "nothing", want: []int{1}, wantLast: -1},
+		{name: "select only one", clicks: []click{{0, false}}, want: []int{0}, wantLast: 0},
+		{name: "ctrl adds", clicks: []click{{2, true}}, want: []int{1, 2}, wantLast: 2},
+		{name: "ctrl removes", clicks: []click{{1, true}}, want: nil, wantLast: 1},
+		{name: "ctrl twice", clicks: []click{{0, true}, {0, true}}, want: []int{1}, wantLast: 0},
+		{name: "click after ctrl", clicks: []click{{0, true}, {2, false}}, want: []int{2}, wantLast: 2},
+		{name: "outside the list", clicks: []click{{3, false}, {-1, true}}, want: []int{1}, wantLast: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := windowList{visible: true, entries: entries(), last: -1}
+			for _, c := range tt.clicks {
+				l.click(c.i, c.ctrl)
+			}
+			if got := l.selected(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v selected, want %v", got, tt.want)
+			}
+			if l.last != tt.wantLast {
+				t.Errorf("got %d as the last click, want %d", l.last, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestWindowListSelectApp(t *testing.T) {
+	tests := []struct {
+		app  string
+		want []int
+	}{
+		{app: "code.exe", want: []int{0, 1, 3}},
+		{app: "thunderbird.exe", want: []int{1, 2}},
+		{app: "CODE.EXE", want: []int{1}},
+		{app: "", want: []int{1}},
+	}
+	for _, tt := range tests {
+		l := windowList{entries: []windowEntry{
+			{title: "main.go", app: "code.exe"},
+			{title: "Inbox", app: "thunderbird.exe", selected: true},
+			{title: "Calendar", app: "thunderbird.exe"},
+			{title: "notes.md", app: "code.exe"},
+		}}
+		l.selectApp(tt.app)
+		if got := l.selected(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v selected, want %v", tt.app, got, tt.want)
+		}
+	}
+}
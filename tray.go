@@ -0,0 +1,95 @@
+package main
+
+import "fmt"
+
+// trayAction is what clicking an item of the tray menu does.
+type trayAction int
+
+const (
+	// noTrayAction is for separators and submenus.
+	noTrayAction trayAction = iota
+	showOverlayAction
+	useLayoutAction
+	toggleAutoTileAction
+	saveWorkspaceAction
+	restoreWorkspaceAction
+	openConfigAction
+	viewLogAction
+	quitAction
+)
+
+// trayItem is an item of the tray menu. Items without a label are separators
+// and items with children are submenus.
+type trayItem struct {
+	label  string
+	action trayAction
+	// arg is the layout or workspace for the actions that need one.
+	arg      string
+	disabled bool
+	checked  bool
+	children []trayItem
+}
+
+// trayState is everything that the tray menu depends on.
+type trayState struct {
+	// layouts are the layouts of the current virtual desktop.
+	layouts []layout
+	// layout is the name of the layout picked last from the menu, "" if
+	// there is none. cols and rows are the grid that windows snap to.
+	layout     string
+	cols, rows int
+	autoTile   bool
+	// workspaces are the names of the workspaces saved on the current
+	// virtual desktop.
+	workspaces []string
+}
+
+// defaultWorkspace is the workspace that the tray menu saves, and that the
+// workspace commands use without a name.
+const defaultWorkspace = "default"
+
+// trayMenu describes the menu of the tray icon in resident mode.
+func trayMenu(s trayState) []trayItem {
+	var layouts []trayItem
+	// Only one layout is checked even if several have the current grid: the
+	// one picked last, or else the first one.
+	checked := -1
+	for _, l := range s.layouts {
+		// Snapping and the hotkeys only use grids.
+		if l.Tree != nil {
+			continue
+		}
+		if l.Cols == s.cols && l.Rows == s.rows && (checked == -1 || l.Name == s.layout) {
+			checked = len(layouts)
+		}
+		layouts = append(layouts, trayItem{
+			label:  fmt.Sprintf("%s (%dx%d)", l.Name, l.Cols, l.Rows),
+			action: useLayoutAction,
+			arg:    l.Name,
+		})
+	}
+	if checked != -1 {
+		layouts[checked].checked = true
+	}
+	var workspaces []trayItem
+	for _, name := range s.workspaces {
+		workspaces = append(workspaces, trayItem{
+			label:  name,
+			action: restoreWorkspaceAction,
+			arg:    name,
+		})
+	}
+	return []trayItem{
+		{label: "Show overlay", action: showOverlayAction},
+		{label: "Layout", children: layouts, disabled: len(layouts) == 0},
+		{label: "Auto-tiling", action: toggleAutoTileAction, checked: s.autoTile},
+		{},
+		{label: "Save workspace", action: saveWorkspaceAction},
+		{label: "Restore workspace", children: workspaces, disabled: len(workspaces) == 0},
+		{},
+		{label: "Open config", action: openConfigAction},
+		{label: "View log", action: viewLogAction},
+		{},
+		{label: "Quit", action: quitAction},
+	}
+}
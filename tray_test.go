@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gonutz/tile_screen/bsp"
+)
+
+// findTrayItem returns the item with the label in the menu or its submenus.
+func findTrayItem(items []trayItem, label string) (trayItem, bool) {
+	for _, item := range items {
+		if item.label == label {
+			return item, true
+		}
+		if found, ok := findTrayItem(item.children, label); ok {
+			return found, true
+		}
+	}
+	return trayItem{}, false
+}
+
+func TestTrayMenu(t *testing.T) {
+	menu := trayMenu(trayState{})
+	var labels []string
+	for _, item := range menu {
+		labels = append(labels, item.label)
+	}
+	want := []string{
+		"Show overlay", "Layout", "Auto-tiling", "",
+		"Save workspace", "Restore workspace", "",
+		"Open config", "View log", "",
+		"Quit",
+	}
+	if !reflect.DeepEqual(labels, want) {
+		t.Fatalf("got %q, want %q", labels, want)
+	}
+	for _, label := range []string{"Layout", "Restore workspace"} {
+		if item, _ := findTrayItem(menu, label); !item.disabled {
+			t.Errorf("%s is enabled without anything in it", label)
+		}
+	}
+	if item, _ := findTrayItem(menu, "Auto-tiling"); item.checked {
+		t.Error("auto-tiling is checked while it is off")
+	}
+}
+
+func TestTrayMenuLayouts(t *testing.T) {
+	split := bsp.NewLeaf()
+	split.SplitLeaf(bsp.LeftRight, 0.6)
+	layouts := []layout{
+		{Name: "halves", Cols: 2, Rows: 1},
+		{Name: "coding", Cols: 3, Rows: 2},
+		{Name: "split", Tree: split},
+		{Name: "writing", Cols: 3, Rows: 2},
+	}
+	tests := []struct {
+		name        string
+		state       trayState
+		wantChecked []string
+	}{
+		{
+			name:        "the only one with the grid",
+			state:       trayState{layouts: layouts, cols: 2, rows: 1},
+			wantChecked: []string{"halves (2x1)"},
+		},
+		{
+			name:        "the first one of the same grid",
+			state:       trayState{layouts: layouts, cols: 3, rows: 2},
+			wantChecked: []string{"coding (3x2)"},
+		},
+		{
+			name:        "the one picked last of the same grid",
+			state:       trayState{layouts: layouts, layout: "writing", cols: 3, rows: 2},
+			wantChecked: []string{"writing (3x2)"},
+		},
+		{
+			name:        "the one picked last with another grid now",
+			state:       trayState{layouts: layouts, layout: "writing", cols: 2, rows: 1},
+			wantChecked: []string{"halves (2x1)"},
+		},
+		{
+			name:  "none with the grid",
+			state: trayState{layouts: layouts, layout: "coding", cols: 4, rows: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, _ := findTrayItem(trayMenu(tt.state), "Layout")
+			if item.disabled {
+				t.Error("the layouts are disabled")
+			}
+			var labels, checked []string
+			for _, l := range item.children {
+				labels = append(labels, l.label)
+				if l.action != useLayoutAction {
+					t.Errorf("%s has action %d", l.label, l.action)
+				}
+				if l.checked {
+					checked = append(checked, l.label)
+				}
+			}
+			// Split layouts are left out, snapping only uses grids.
+			want := []string{"halves (2x1)", "coding (3x2)", "writing (3x2)"}
+			if !reflect.DeepEqual(labels, want) {
+				t.Errorf("got layouts %q, want %q", labels, want)
+			}
+			if !reflect.DeepEqual(checked, tt.wantChecked) {
+				t.Errorf("got %q checked, want %q", checked, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestTrayMenuWorkspaces(t *testing.T) {
+	menu := trayMenu(trayState{workspaces: []string{"default", "coding"}, autoTile: true})
+	item, _ := findTrayItem(menu, "Restore workspace")
+	if item.disabled {
+		t.Error("restoring workspaces is disabled")
+	}
+	want := []trayItem{
+		{label: "default", action: restoreWorkspaceAction, arg: "default"},
+		{label: "coding", action: restoreWorkspaceAction, arg: "coding"},
+	}
+	if !reflect.DeepEqual(item.children, want) {
+		t.Errorf("got %+v, want %+v", item.children, want)
+	}
+	if item, _ := findTrayItem(menu, "Auto-tiling"); !item.checked {
+		t.Error("auto-tiling is not checked while it is on")
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32"
+)
+
+const (
+	// trayMessage is sent to the resident window for mouse input on the tray
+	// icon.
+	trayMessage = w32.WM_APP + 1
+	trayIconID  = 1
+)
+
+// taskbarCreatedMessage is broadcast when Explorer restarts, the tray icon
+// has to be added again then.
+var taskbarCreatedMessage uint32
+
+func (r *resident) addTrayIcon() error {
+	if taskbarCreatedMessage == 0 {
+		name, _ := syscall.UTF16PtrFromString("TaskbarCreated")
+		msg, _, _ := registerWindowMessage.Call(uintptr(unsafe.Pointer(name)))
+		taskbarCreatedMessage = uint32(msg)
+	}
+	data := r.trayIconData()
+	data.Flags = nifMessage | nifIcon | nifTip
+	data.CallbackMessage = trayMessage
+	data.Icon = w32.LoadIcon(0, w32.MakeIntResource(w32.IDI_APPLICATION))
+	copy(data.Tip[:len(data.Tip)-1], syscall.StringToUTF16("tile_screen"))
+	if ret, _, _ := shellNotifyIcon.Call(nimAdd, uintptr(unsafe.Pointer(&data))); ret == 0 {
+		return fmt.Errorf("unable to add the tray icon: %w", lastError("Shell_NotifyIcon"))
+	}
+	return nil
+}
+
+func (r *resident) removeTrayIcon() {
+	data := r.trayIconData()
+	shellNotifyIcon.Call(nimDelete, uintptr(unsafe.Pointer(&data)))
+}
+
+// notify shows a message next to the tray icon.
+func (r *resident) notify(format string, a ...interface{}) {
+	data := r.trayIconData()
+	data.Flags = nifInfo
+	copy(data.Info[:len(data.Info)-1], syscall.StringToUTF16(fmt.Sprintf(format, a...)))
+	shellNotifyIcon.Call(nimModify, uintptr(unsafe.Pointer(&data)))
+}
+
+func (r *resident) trayIconData() notifyIconData {
+	data := notifyIconData{Wnd: r.window, ID: trayIconID}
+	data.CbSize = uint32(unsafe.Sizeof(data))
+	return data
+}
+
+// handleTrayMessage handles mouse input on the tray icon. Right-clicking it
+// shows the menu, double-clicking it shows the overlay.
+func (r *resident) handleTrayMessage(l uintptr) {
+	switch l & 0xFFFF {
+	case w32.WM_RBUTTONUP, w32.WM_CONTEXTMENU:
+		r.showTrayMenu()
+	case w32.WM_LBUTTONDBLCLK:
+		r.runTrayItem(trayItem{action: showOverlayAction})
+	}
+}
+
+func (r *resident) trayState() trayState {
+	vd := desktops()
+	current := vd.current()
+	s := trayState{
+		layouts:  r.cfg.layoutsOn(vd, current),
+		layout:   r.layout,
+		autoTile: r.auto != nil,
+	}
+	s.cols, s.rows = loadGridSize()
+	all, err := loadWorkspaces()
+	if err != nil {
+		log.warnf("unable to list the workspaces: %v", err)
+	}
+	for _, w := range all {
+		if w.Desktop == current {
+			s.workspaces = append(s.workspaces, w.Name)
+		}
+	}
+	return s
+}
+
+func (r *resident) showTrayMenu() {
+	var items []trayItem
+	menu := buildMenu(trayMenu(r.trayState()), &items)
+	defer w32.DestroyMenu(menu)
+	// The menu only closes when clicking elsewhere if our window is in the
+	// foreground.
+	w32.SetForegroundWindow(r.window)
+	x, y, _ := w32.GetCursorPos()
+	id := w32.TrackPopupMenu(menu, tpmReturnCmd|tpmRightButton, x, y, r.window, nil)
+	w32.PostMessage(r.window, w32.WM_NULL, 0, 0)
+	if 0 < id && id <= len(items) {
+		r.runTrayItem(items[id-1])
+	}
+}
+
+// buildMenu creates the menu for the items. Every item that does something
+// is appended to all, its menu ID is its index in all plus one.
+func buildMenu(items []trayItem, all *[]trayItem) w32.HMENU {
+	menu := w32.CreatePopupMenu()
+	for _, item := range items {
+		var flags uint = w32.MF_STRING
+		if item.disabled {
+			flags |= w32.MF_GRAYED
+		}
+		if item.checked {
+			flags |= w32.MF_CHECKED
+		}
+		switch {
+		case item.label == "":
+			w32.AppendMenu(menu, w32.MF_SEPARATOR, 0, "")
+		case item.children != nil || item.action == noTrayAction:
+			sub := buildMenu(item.children, all)
+			w32.AppendMenu(menu, flags|w32.MF_POPUP, uintptr(sub), item.label)
+		default:
+			*all = append(*all, item)
+			w32.AppendMenu(menu, flags, uintptr(len(*all)), item.label)
+		}
+	}
+	return menu
+}
+
+func (r *resident) runTrayItem(item trayItem) {
+	switch item.action {
+	case showOverlayAction:
+		if err := r.showOverlay(); err != nil {
+			log.errorf("unable to show the overlay: %v", err)
+		}
+	case useLayoutAction:
+		l, ok := r.cfg.findLayout(item.arg)
+		if !ok {
+			return
+		}
+		if err := saveGridSize(l.Cols, l.Rows); err != nil {
+			log.errorf("unable to save the grid size: %v", err)
+			return
+		}
+		r.layout = l.Name
+		log.infof("using layout %q", l.Name)
+	case toggleAutoTileAction:
+		r.toggleAutoTile()
+	case saveWorkspaceAction:
+		w, err := saveCurrentWorkspace(r.cfg, defaultWorkspace)
+		if err != nil {
+			log.errorf("unable to save the workspace: %v", err)
+			r.notify("Unable to save the workspace: %v", err)
+			return
+		}
+		r.notify("Saved %d windows", len(w.Windows))
+	case restoreWorkspaceAction:
+		restored, missing, err := restoreWorkspace(r.cfg, item.arg, desktops().current())
+		if err != nil {
+			log.errorf("unable to restore workspace %q: %v", item.arg, err)
+			r.notify("Unable to restore the workspace: %v", err)
+			return
+		}
+		if len(missing) > 0 {
+			r.notify("Restored %d windows, %d are not open", restored, len(missing))
+		}
+	case openConfigAction:
+		if _, err := os.Stat(configPath()); os.IsNotExist(err) {
+			// Missing fields keep their defaults, so an empty object is a
+			// valid config to start from.
+			os.MkdirAll(configDir(), 0777)
+			if err := ioutil.WriteFile(configPath(), []byte("{\n}\n"), 0666); err != nil {
+				log.errorf("unable to create the config file: %v", err)
+			}
+		}
+		r.openFile(configPath())
+	case viewLogAction:
+		r.openFile(log.path)
+	case quitAction:
+		w32.PostQuitMessage(0)
+	}
+}
+
+// showOverlay shows the overlay for the top-most window that it can be used
+// for, e.g. the one that was active before the tray menu opened. Until the
+// user places the window or closes the overlay, it takes the mouse and the
+// keyboard like the overlay of the program started without arguments.
+func (r *resident) showOverlay() error {
+	o := r.overlay
+	if r.interactive {
+		w32.SetForegroundWindow(o.window)
+		return nil
+	}
+	if r.dragged != 0 {
+		return errors.New("a window is being snapped")
+	}
+	target := topTarget()
+	if target == 0 {
+		return errors.New("there is no window to place")
+	}
+	monitor := w32.MonitorFromWindow(target, w32.MONITOR_DEFAULTTONEAREST)
+	r.setInteractive(true)
+	o.useTree(nil, "")
+	o.windows = windowList{}
+	o.windowHandles = nil
+	o.cols, o.rows = loadGridSize()
+	o.setTarget(target)
+	if err := o.showOn(monitor); err != nil {
+		r.closeOverlay()
+		return err
+	}
+	w32.SetForegroundWindow(o.window)
+	return nil
+}
+
+// setInteractive switches the overlay between following a window that is
+// being snapped, which is passive, and taking input itself for showOverlay.
+func (r *resident) setInteractive(on bool) {
+	o := r.overlay
+	r.interactive = on
+	o.passive = !on
+	o.drag.bindings = r.cfg.Mouse
+	exStyle := w32.GetWindowLongPtr(o.window, w32.GWL_EXSTYLE)
+	if on {
+		exStyle &^= passiveOverlayStyle
+	} else {
+		exStyle |= passiveOverlayStyle
+		o.drag.bindings.Left = "place-tile"
+	}
+	w32.SetWindowLongPtr(o.window, w32.GWL_EXSTYLE, exStyle)
+}
+
+// closeOverlay hides the overlay. After showOverlay, the overlay becomes
+// passive again and the target gets the focus back.
+func (r *resident) closeOverlay() {
+	o := r.overlay
+	o.handleInput(inputEvent{kind: cancelDrag})
+	o.hide()
+	if r.interactive {
+		r.setInteractive(false)
+		w32.SetForegroundWindow(o.target)
+	}
+}
+
+// openFile opens the file in its associated program, or in Notepad if it has
+// none, e.g. for .json files.
+func (r *resident) openFile(path string) {
+	if err := w32.ShellExecute(r.window, "open", path, "", "", w32.SW_SHOWNORMAL); err == nil {
+		return
+	}
+	if err := exec.Command("notepad.exe", path).Start(); err != nil {
+		log.errorf("unable to open %s: %v", path, err)
+	}
+}
+
+// toggleAutoTile turns auto-tiling on or off. If the config does not say
+// which layout to use, it uses the master-stack layout.
+func (r *resident) toggleAutoTile() {
+	if r.auto != nil {
+		if r.auto.timer != 0 {
+			killTimer.Call(0, r.auto.timer)
+		}
+		r.auto = nil
+		log.infof("auto-tiling turned off")
+		return
+	}
+	cfg := r.cfg
+	if cfg.AutoTile == "" {
+		cfg.AutoTile = masterStack.String()
+	}
+	r.auto = newAutoTiler(cfg)
+	log.infof("auto-tiling with the %s layout", r.auto.layout)
+	r.auto.reflow()
+}
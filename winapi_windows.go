@@ -0,0 +1,391 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32"
+)
+
+// This file contains the Win32 functions and types that are missing in the w32
+// package.
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	gdi32    = syscall.NewLazyDLL("gdi32.dll")
+	dwmapi   = syscall.NewLazyDLL("dwmapi.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+	shcore   = syscall.NewLazyDLL("shcore.dll")
+	ole32    = syscall.NewLazyDLL("ole32.dll")
+	shell32  = syscall.NewLazyDLL("shell32.dll")
+
+	sendMessageTimeout         = user32.NewProc("SendMessageTimeoutW")
+	updateLayeredWindow        = user32.NewProc("UpdateLayeredWindow")
+	systemParametersInfo       = user32.NewProc("SystemParametersInfoW")
+	setWinEventHookProc        = user32.NewProc("SetWinEventHook")
+	unhookWinEventProc         = user32.NewProc("UnhookWinEvent")
+	setWindowsHookExProc       = user32.NewProc("SetWindowsHookExW")
+	enumWindowsProc            = user32.NewProc("EnumWindows")
+	killTimer                  = user32.NewProc("KillTimer")
+	registerHotKey             = user32.NewProc("RegisterHotKey")
+	getMonitorInfoW            = user32.NewProc("GetMonitorInfoW")
+	enumDisplayDevices         = user32.NewProc("EnumDisplayDevicesW")
+	registerWindowMessage      = user32.NewProc("RegisterWindowMessageW")
+	enumDisplayMonitorsProc    = user32.NewProc("EnumDisplayMonitors")
+	setThreadDpiAwareness      = user32.NewProc("SetThreadDpiAwarenessContext")
+
+	createRectRgn      = gdi32.NewProc("CreateRectRgn")
+	createRoundRectRgn = gdi32.NewProc("CreateRoundRectRgn")
+	combineRgn         = gdi32.NewProc("CombineRgn")
+	selectClipRgn      = gdi32.NewProc("SelectClipRgn")
+	gdiFlush           = gdi32.NewProc("GdiFlush")
+
+	dwmUpdateThumbnailProperties = dwmapi.NewProc("DwmUpdateThumbnailProperties")
+	dwmGetWindowAttribute        = dwmapi.NewProc("DwmGetWindowAttribute")
+
+	attachConsoleProc             = kernel32.NewProc("AttachConsole")
+	queryFullProcessImageNameProc = kernel32.NewProc("QueryFullProcessImageNameW")
+	processIdToSessionId          = kernel32.NewProc("ProcessIdToSessionId")
+	createNamedPipe               = kernel32.NewProc("CreateNamedPipeW")
+	connectNamedPipe              = kernel32.NewProc("ConnectNamedPipe")
+	waitNamedPipe                 = kernel32.NewProc("WaitNamedPipeW")
+
+	getDpiForMonitor = shcore.NewProc("GetDpiForMonitor")
+
+	coCreateInstance = ole32.NewProc("CoCreateInstance")
+
+	shellNotifyIcon = shell32.NewProc("Shell_NotifyIconW")
+)
+
+const (
+	smtoAbortIfHung = 0x0002
+	ulwAlpha        = 0x0002
+	acSrcAlpha      = 0x01
+	rgnDiff         = 4
+
+	eventSystemMoveSizeStart = 0x000A
+	eventSystemMoveSizeEnd   = 0x000B
+	eventSystemMinimizeStart = 0x0016
+	eventSystemMinimizeEnd   = 0x0017
+	eventObjectDestroy       = 0x8001
+	eventObjectShow          = 0x8002
+	eventObjectHide          = 0x8003
+	winEventOutOfContext     = 0x0000
+	winEventSkipOwnProcess   = 0x0002
+	objidWindow              = 0
+	childidSelf              = 0
+
+	modAlt      = 0x0001
+	modControl  = 0x0002
+	modShift    = 0x0004
+	modWin      = 0x0008
+	modNoRepeat = 0x4000
+
+	eddGetDeviceInterfaceName = 0x00000001
+	displayDeviceActive       = 0x00000001
+
+	dwmwaCloaked = 14
+
+	nimAdd     = 0x0
+	nimModify  = 0x1
+	nimDelete  = 0x2
+	nifMessage = 0x1
+	nifIcon    = 0x2
+	nifTip     = 0x4
+	nifInfo    = 0x10
+
+	tpmRightButton = 0x0002
+	tpmReturnCmd   = 0x0100
+
+	pipeAccessDuplex          = 0x00000003
+	fileFlagFirstPipeInstance = 0x00080000
+	pipeRejectRemoteClients   = 0x00000008
+	pipeUnlimitedInstances    = 255
+	errorPipeBusy             = syscall.Errno(231)
+	errorPipeConnected        = syscall.Errno(535)
+
+	clsctxAll = 0x17
+
+	mdtEffectiveDPI = 0
+	defaultDPI      = 96
+	// dpiAwarenessPerMonitorV2 is DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2.
+	dpiAwarenessPerMonitorV2 = ^uintptr(3)
+)
+
+type minMaxInfo struct {
+	Reserved     w32.POINT
+	MaxSize      w32.POINT
+	MaxPosition  w32.POINT
+	MinTrackSize w32.POINT
+	MaxTrackSize w32.POINT
+}
+
+// displayDevice is DISPLAY_DEVICEW.
+type displayDevice struct {
+	Cb           uint32
+	DeviceName   [32]uint16
+	DeviceString [128]uint16
+	StateFlags   uint32
+	DeviceID     [128]uint16
+	DeviceKey    [128]uint16
+}
+
+// notifyIconData is NOTIFYICONDATAW.
+type notifyIconData struct {
+	CbSize           uint32
+	Wnd              w32.HWND
+	ID               uint32
+	Flags            uint32
+	CallbackMessage  uint32
+	Icon             w32.HICON
+	Tip              [128]uint16
+	State            uint32
+	StateMask        uint32
+	Info             [256]uint16
+	TimeoutOrVersion uint32
+	InfoTitle        [64]uint16
+	InfoFlags        uint32
+	GuidItem         w32.GUID
+	BalloonIcon      w32.HICON
+}
+
+// msllHookStruct is what a WH_MOUSE_LL hook gets for each mouse event.
+type msllHookStruct struct {
+	Pt          w32.POINT
+	MouseData   uint32
+	Flags       uint32
+	Time        uint32
+	DwExtraInfo uintptr
+}
+
+// attachConsole makes output visible when the program is run from a console.
+// Since it is built as a GUI program, it does not get a console of its own.
+// It reports whether there is a console.
+func attachConsole() bool {
+	const attachParentProcess = ^uintptr(0)
+	if ret, _, _ := attachConsoleProc.Call(attachParentProcess); ret == 0 {
+		return false
+	}
+	if h, err := syscall.GetStdHandle(syscall.STD_OUTPUT_HANDLE); err == nil {
+		os.Stdout = os.NewFile(uintptr(h), "stdout")
+	}
+	if h, err := syscall.GetStdHandle(syscall.STD_ERROR_HANDLE); err == nil {
+		os.Stderr = os.NewFile(uintptr(h), "stderr")
+	}
+	return true
+}
+
+// isSizingBorder reports whether the point in screen coordinates is on one
+// of the borders that resize the window. If the window does not answer in
+// time, it is not.
+func isSizingBorder(window w32.HWND, x, y int) bool {
+	var hit uintptr
+	ret, _, _ := sendMessageTimeout.Call(
+		uintptr(window),
+		w32.WM_NCHITTEST,
+		0,
+		uintptr(uint16(x))|uintptr(uint16(y))<<16,
+		smtoAbortIfHung,
+		200,
+		uintptr(unsafe.Pointer(&hit)),
+	)
+	return ret != 0 && w32.HTSIZEFIRST <= int32(hit) && int32(hit) <= w32.HTSIZELAST
+}
+
+// windowSizeLimits asks the window for its minimum and maximum track size. If
+// the window does not answer in time, the system defaults are returned.
+func windowSizeLimits(window w32.HWND) sizeLimits {
+	info := minMaxInfo{
+		MinTrackSize: w32.POINT{
+			X: int32(w32.GetSystemMetrics(w32.SM_CXMINTRACK)),
+			Y: int32(w32.GetSystemMetrics(w32.SM_CYMINTRACK)),
+		},
+		MaxTrackSize: w32.POINT{
+			X: int32(w32.GetSystemMetrics(w32.SM_CXMAXTRACK)),
+			Y: int32(w32.GetSystemMetrics(w32.SM_CYMAXTRACK)),
+		},
+	}
+	var result uintptr
+	sendMessageTimeout.Call(
+		uintptr(window),
+		w32.WM_GETMINMAXINFO,
+		0,
+		uintptr(unsafe.Pointer(&info)),
+		smtoAbortIfHung,
+		200,
+		uintptr(unsafe.Pointer(&result)),
+	)
+	return sizeLimits{
+		minWidth:  int(info.MinTrackSize.X),
+		minHeight: int(info.MinTrackSize.Y),
+		maxWidth:  int(info.MaxTrackSize.X),
+		maxHeight: int(info.MaxTrackSize.Y),
+	}
+}
+
+// setWinEventHook calls the callback, created with syscall.NewCallback, for
+// the events from min to max of all processes except this one.
+func setWinEventHook(min, max uint32, callback uintptr) (uintptr, error) {
+	hook, _, _ := setWinEventHookProc.Call(
+		uintptr(min), uintptr(max),
+		0, callback,
+		0, 0,
+		winEventOutOfContext|winEventSkipOwnProcess,
+	)
+	if hook == 0 {
+		return 0, lastError("SetWinEventHook")
+	}
+	return hook, nil
+}
+
+func unhookWinEvent(hook uintptr) {
+	unhookWinEventProc.Call(hook)
+}
+
+// setWindowsHook installs a global hook. Unlike w32.SetWindowsHookEx it takes
+// a callback created once with syscall.NewCallback, so the hook can be
+// installed and removed any number of times.
+func setWindowsHook(id int, callback uintptr) (w32.HHOOK, error) {
+	hook, _, _ := setWindowsHookExProc.Call(
+		uintptr(id), callback, uintptr(w32.GetModuleHandle("")), 0,
+	)
+	if hook == 0 {
+		return 0, lastError("SetWindowsHookEx")
+	}
+	return w32.HHOOK(hook), nil
+}
+
+var enumWindowsCallback = syscall.NewCallback(
+	func(window w32.HWND, windows *[]w32.HWND) uintptr {
+		*windows = append(*windows, window)
+		return 1
+	},
+)
+
+// enumWindows returns all top-level windows, from top to bottom in z-order.
+// Unlike w32.EnumWindows it does not create a new callback every time.
+func enumWindows() []w32.HWND {
+	var windows []w32.HWND
+	enumWindowsProc.Call(enumWindowsCallback, uintptr(unsafe.Pointer(&windows)))
+	return windows
+}
+
+// windowApp returns the file name of the executable that the window belongs
+// to, or "" if it cannot be determined.
+func windowApp(window w32.HWND) string {
+	_, pid := w32.GetWindowThreadProcessId(window)
+	process := w32.OpenProcess(w32.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if process == 0 {
+		return ""
+	}
+	defer w32.CloseHandle(process)
+	var buf [syscall.MAX_PATH]uint16
+	size := uint32(len(buf))
+	ret, _, _ := queryFullProcessImageNameProc.Call(
+		uintptr(process), 0,
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if ret == 0 {
+		return ""
+	}
+	return filepath.Base(syscall.UTF16ToString(buf[:size]))
+}
+
+var enumMonitorsCallback = syscall.NewCallback(
+	func(monitor w32.HMONITOR, dc w32.HDC, r *w32.RECT, monitors *[]w32.HMONITOR) uintptr {
+		*monitors = append(*monitors, monitor)
+		return 1
+	},
+)
+
+// enumMonitors returns all display monitors.
+func enumMonitors() []w32.HMONITOR {
+	var monitors []w32.HMONITOR
+	enumDisplayMonitorsProc.Call(0, 0, enumMonitorsCallback, uintptr(unsafe.Pointer(&monitors)))
+	return monitors
+}
+
+// monitorDevice returns the device name of the monitor, like \\.\DISPLAY1.
+// w32.GetMonitorInfo only knows the short MONITORINFO which has no name.
+func monitorDevice(monitor w32.HMONITOR) string {
+	var info w32.MONITORINFOEX
+	info.CbSize = uint32(unsafe.Sizeof(info))
+	if ret, _, _ := getMonitorInfoW.Call(uintptr(monitor), uintptr(unsafe.Pointer(&info))); ret == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(info.SzDevice[:])
+}
+
+// monitorDeviceID returns the device interface path and the name of the
+// monitor attached to the display device, e.g. \\.\DISPLAY1. For a path
+// like \\?\DISPLAY#DEL4109#... the name is "Dell U2415" or "Generic PnP
+// Monitor". It returns empty strings if Windows does not know the monitor.
+func monitorDeviceID(device string) (path, name string) {
+	deviceName, err := syscall.UTF16PtrFromString(device)
+	if err != nil {
+		return "", ""
+	}
+	for i := uintptr(0); ; i++ {
+		var d displayDevice
+		d.Cb = uint32(unsafe.Sizeof(d))
+		ret, _, _ := enumDisplayDevices.Call(
+			uintptr(unsafe.Pointer(deviceName)), i,
+			uintptr(unsafe.Pointer(&d)),
+			eddGetDeviceInterfaceName,
+		)
+		if ret == 0 {
+			return "", ""
+		}
+		if d.StateFlags&displayDeviceActive != 0 {
+			return syscall.UTF16ToString(d.DeviceID[:]),
+				syscall.UTF16ToString(d.DeviceString[:])
+		}
+	}
+}
+
+// isCloaked reports whether DWM hides the window although it is visible.
+// w32.DwmGetWindowAttribute does not support DWMWA_CLOAKED.
+func isCloaked(window w32.HWND) bool {
+	var cloaked uint32
+	ret, _, _ := dwmGetWindowAttribute.Call(
+		uintptr(window), dwmwaCloaked,
+		uintptr(unsafe.Pointer(&cloaked)), unsafe.Sizeof(cloaked),
+	)
+	return ret == 0 && cloaked != 0
+}
+
+// monitorDPI returns the effective DPI of the monitor, 96 means 100%
+// scaling. Before Windows 8.1 all monitors have the system DPI which is
+// reported as 96 here, it does not matter when moving windows between them.
+func monitorDPI(monitor w32.HMONITOR) int {
+	if getDpiForMonitor.Find() != nil {
+		return defaultDPI
+	}
+	var x, y uint32
+	ret, _, _ := getDpiForMonitor.Call(
+		uintptr(monitor), mdtEffectiveDPI,
+		uintptr(unsafe.Pointer(&x)), uintptr(unsafe.Pointer(&y)),
+	)
+	if ret != 0 || x == 0 {
+		return defaultDPI
+	}
+	return int(x)
+}
+
+// usePhysicalPixels makes the calling thread per-monitor DPI aware so window
+// and monitor coordinates are in physical pixels. It returns a function that
+// restores the previous DPI awareness. Before Windows 10 this does nothing.
+func usePhysicalPixels() (restore func()) {
+	if setThreadDpiAwareness.Find() != nil {
+		return func() {}
+	}
+	old, _, _ := setThreadDpiAwareness.Call(dpiAwarenessPerMonitorV2)
+	return func() {
+		if old != 0 {
+			setThreadDpiAwareness.Call(old)
+		}
+	}
+}
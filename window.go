@@ -0,0 +1,220 @@
+package main
+
+// windowState describes how a window is currently shown. Each state needs
+// different handling when placing the window into tiles.
+type windowState int
+
+const (
+	// normalWindow is a restored window, its window rect is its restore rect.
+	normalWindow windowState = iota
+	// minimizedWindow is iconic, its restore rect is only in its placement.
+	minimizedWindow
+	// maximizedWindow covers the work area, its restore rect is only in its
+	// placement.
+	maximizedWindow
+	// snappedWindow was arranged by Aero Snap. It looks like a normal window
+	// but its placement still holds the rect from before it was snapped.
+	snappedWindow
+	// fullscreenWindow covers the whole monitor without a caption, like games
+	// and video players do.
+	fullscreenWindow
+)
+
+func (s windowState) String() string {
+	switch s {
+	case normalWindow:
+		return "normal"
+	case minimizedWindow:
+		return "minimized"
+	case maximizedWindow:
+		return "maximized"
+	case snappedWindow:
+		return "snapped"
+	case fullscreenWindow:
+		return "fullscreen"
+	}
+	return "unknown"
+}
+
+// windowFacts are the properties of a window that determine its state. All
+// rects are in screen coordinates.
+type windowFacts struct {
+	minimized  bool
+	maximized  bool
+	hasCaption bool
+	windowRect rect
+	normalRect rect
+	monitor    rect
+}
+
+func classifyWindowState(f windowFacts) windowState {
+	if f.minimized {
+		return minimizedWindow
+	}
+	if f.maximized {
+		return maximizedWindow
+	}
+	if !f.hasCaption && covers(f.windowRect, f.monitor) {
+		return fullscreenWindow
+	}
+	if f.windowRect != f.normalRect {
+		return snappedWindow
+	}
+	return normalWindow
+}
+
+// covers reports whether a completely contains b.
+func covers(a, b rect) bool {
+	return a.left <= b.left && a.top <= b.top &&
+		a.right >= b.right && a.bottom >= b.bottom
+}
+
+// builtinIgnoredClasses are the window classes of the shell. They are never
+// moved, no matter what the config says, see windowRules.
+var builtinIgnoredClasses = []string{
+	"Shell_TrayWnd",          // the taskbar
+	"Shell_SecondaryTrayWnd", // the taskbar on other monitors
+	"Progman",                // the desktop
+	"WorkerW",                // the desktop when the wallpaper is animated
+	"#32769",                 // the desktop window behind all of these
+}
+
+// windowAttributes are the properties of a window that decide what it can be
+// used for, see classifyWindow.
+type windowAttributes struct {
+	class   string
+	visible bool
+	// cloaked windows are hidden by DWM even though they are visible, e.g.
+	// UWP apps that are suspended and windows on other virtual desktops.
+	cloaked bool
+	// otherDesktop is set for windows on another virtual desktop.
+	otherDesktop bool
+	toolWindow   bool
+	owned        bool
+	hasTitle     bool
+	// own windows belong to this program, like the overlay.
+	own bool
+}
+
+// windowPurpose is what a window is looked at for.
+type windowPurpose int
+
+const (
+	// asTarget is the window that the overlay comes up for.
+	asTarget windowPurpose = iota
+	// forTiling are the windows tiled together, e.g. in the window list.
+	forTiling
+	// forWorkspaces are like forTiling but on all virtual desktops.
+	forWorkspaces
+)
+
+// windowRule excludes windows for which exclude is true. It only applies to
+// the given purposes, or to all of them if there are none.
+type windowRule struct {
+	reason   string
+	purposes []windowPurpose
+	exclude  func(a windowAttributes) bool
+}
+
+// shellWindow is the reason given for the windows of the shell.
+const shellWindow = "part of the shell"
+
+// windowRules are checked in order, the first one that excludes a window
+// gives the reason.
+var windowRules = []windowRule{
+	{
+		reason:  "invisible",
+		exclude: func(a windowAttributes) bool { return !a.visible },
+	},
+	{
+		reason:   "on another desktop",
+		purposes: []windowPurpose{asTarget, forTiling},
+		exclude:  func(a windowAttributes) bool { return a.otherDesktop },
+	},
+	{
+		// Windows on other desktops are cloaked as well, they are handled
+		// by the rule above.
+		reason:  "cloaked",
+		exclude: func(a windowAttributes) bool { return a.cloaked && !a.otherDesktop },
+	},
+	{
+		reason:  "one of our own",
+		exclude: func(a windowAttributes) bool { return a.own },
+	},
+	{
+		reason: shellWindow,
+		exclude: func(a windowAttributes) bool {
+			for _, class := range builtinIgnoredClasses {
+				if a.class == class {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		reason:  "a tool window",
+		exclude: func(a windowAttributes) bool { return a.toolWindow },
+	},
+	{
+		// Dialogs are fine as targets but they move with their owner, they
+		// are not tiled on their own.
+		reason:   "owned by another window",
+		purposes: []windowPurpose{forTiling, forWorkspaces},
+		exclude:  func(a windowAttributes) bool { return a.owned },
+	},
+	{
+		reason:   "untitled",
+		purposes: []windowPurpose{forTiling, forWorkspaces},
+		exclude:  func(a windowAttributes) bool { return !a.hasTitle },
+	},
+}
+
+// classifyWindow reports whether the window can be used for the purpose. If
+// not, it returns the reason.
+func classifyWindow(a windowAttributes, p windowPurpose) (reason string, ok bool) {
+	for _, rule := range windowRules {
+		applies := len(rule.purposes) == 0
+		for _, q := range rule.purposes {
+			applies = applies || q == p
+		}
+		if applies && rule.exclude(a) {
+			return rule.reason, false
+		}
+	}
+	return "", true
+}
+
+// ignoreWindow reports whether the config says to leave a window of the
+// given class and state alone. The shell is left alone by windowRules.
+func ignoreWindow(class string, state windowState, c config) bool {
+	if state == fullscreenWindow && !c.MoveFullscreen {
+		return true
+	}
+	for _, ignored := range c.IgnoreClasses {
+		if class == ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceRect converts a rect from screen to workspace coordinates, which
+// are relative to the top-left of the work area. Window placements use
+// workspace coordinates for all windows except tool windows, their rect is
+// returned as is.
+func workspaceRect(screen, work, monitor rect, toolWindow bool) rect {
+	if toolWindow {
+		return screen
+	}
+	return screen.offset(monitor.left-work.left, monitor.top-work.top)
+}
+
+// screenRect converts a rect from a window placement back to screen
+// coordinates, see workspaceRect.
+func screenRect(workspace, work, monitor rect, toolWindow bool) rect {
+	if toolWindow {
+		return workspace
+	}
+	return workspace.offset(work.left-monitor.left, work.top-monitor.top)
+}
@@ -0,0 +1,309 @@
+package main
+
+import "testing"
+
+func TestClassifyWindow(t *testing.T) {
+	// normal is an ordinary top-level window, the cases change one thing
+	// about it.
+	normal := windowAttributes{class: "Notepad", visible: true, hasTitle: true}
+	with := func(change func(a *windowAttributes)) windowAttributes {
+		a := normal
+		change(&a)
+		return a
+	}
+	type want struct {
+		target, tiling, workspaces string
+	}
+	tests := []struct {
+		name string
+		a    windowAttributes
+		// want has the reason for each purpose, "" if the window can be
+		// used.
+		want want
+	}{
+		{
+			name: "normal",
+			a:    normal,
+		},
+		{
+			name: "invisible",
+			a:    with(func(a *windowAttributes) { a.visible = false }),
+			want: want{"invisible", "invisible", "invisible"},
+		},
+		{
+			name: "on another desktop",
+			a:    with(func(a *windowAttributes) { a.otherDesktop, a.cloaked = true, true }),
+			want: want{"on another desktop", "on another desktop", ""},
+		},
+		{
+			name: "cloaked",
+			a:    with(func(a *windowAttributes) { a.cloaked = true }),
+			want: want{"cloaked", "cloaked", "cloaked"},
+		},
+		{
+			name: "own",
+			a:    with(func(a *windowAttributes) { a.own = true }),
+			want: want{"one of our own", "one of our own", "one of our own"},
+		},
+		{
+			name: "taskbar",
+			a:    with(func(a *windowAttributes) { a.class = "Shell_TrayWnd" }),
+			want: want{shellWindow, shellWindow, shellWindow},
+		},
+		{
+			name: "taskbar on another monitor",
+			a:    with(func(a *windowAttributes) { a.class = "Shell_SecondaryTrayWnd" }),
+			want: want{shellWindow, shellWindow, shellWindow},
+		},
+		{
+			name: "desktop",
+			a:    with(func(a *windowAttributes) { a.class = "Progman" }),
+			want: want{shellWindow, shellWindow, shellWindow},
+		},
+		{
+			name: "animated desktop",
+			a:    with(func(a *windowAttributes) { a.class = "WorkerW" }),
+			want: want{shellWindow, shellWindow, shellWindow},
+		},
+		{
+			name: "class names are case sensitive",
+			a:    with(func(a *windowAttributes) { a.class = "progman" }),
+		},
+		{
+			name: "tool window",
+			a:    with(func(a *windowAttributes) { a.toolWindow = true }),
+			want: want{"a tool window", "a tool window", "a tool window"},
+		},
+		{
+			name: "dialog",
+			a:    with(func(a *windowAttributes) { a.owned = true }),
+			want: want{"", "owned by another window", "owned by another window"},
+		},
+		{
+			name: "untitled",
+			a:    with(func(a *windowAttributes) { a.hasTitle = false }),
+			want: want{"", "untitled", "untitled"},
+		},
+		{
+			name: "the first rule wins",
+			a: with(func(a *windowAttributes) {
+				a.visible = false
+				a.class = "Progman"
+				a.hasTitle = false
+			}),
+			want: want{"invisible", "invisible", "invisible"},
+		},
+		{
+			name: "shell before tool window",
+			a: with(func(a *windowAttributes) {
+				a.class = "Shell_TrayWnd"
+				a.toolWindow = true
+			}),
+			want: want{shellWindow, shellWindow, shellWindow},
+		},
+		{
+			name: "untitled dialog on another desktop",
+			a: with(func(a *windowAttributes) {
+				a.otherDesktop, a.cloaked = true, true
+				a.owned = true
+				a.hasTitle = false
+			}),
+			want: want{"on another desktop", "on another desktop", "owned by another window"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, p := range []struct {
+				purpose windowPurpose
+				name    string
+				want    string
+			}{
+				{asTarget, "target", tt.want.target},
+				{forTiling, "tiling", tt.want.tiling},
+				{forWorkspaces, "workspaces", tt.want.workspaces},
+			} {
+				reason, ok := classifyWindow(tt.a, p.purpose)
+				if reason != p.want || ok != (p.want == "") {
+					t.Errorf("as %s: got %q %v, want %q", p.name, reason, ok, p.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIgnoreWindow(t *testing.T) {
+	cfg := config{IgnoreClasses: []string{"ConsoleWindowClass"}}
+	moveFullscreen := cfg
+	moveFullscreen.MoveFullscreen = true
+	tests := []struct {
+		name  string
+		class string
+		state windowState
+		cfg   config
+		want  bool
+	}{
+		{name: "normal", class: "Notepad", state: normalWindow, cfg: cfg},
+		{name: "maximized", class: "Notepad", state: maximizedWindow, cfg: cfg},
+		{name: "ignored class", class: "ConsoleWindowClass", state: normalWindow, cfg: cfg, want: true},
+		{name: "fullscreen", class: "Notepad", state: fullscreenWindow, cfg: cfg, want: true},
+		{name: "fullscreen allowed", class: "Notepad", state: fullscreenWindow, cfg: moveFullscreen},
+		{name: "the shell is up to windowRules", class: "Progman", state: normalWindow, cfg: cfg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ignoreWindow(tt.class, tt.state, tt.cfg); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyWindowState(t *testing.T) {
+	monitor := rect{0, 0, 1920, 1080}
+	// normal is a window with a caption that is where its placement says.
+	normal := windowFacts{
+		hasCaption: true,
+		windowRect: rect{100, 100, 900, 700},
+		normalRect: rect{100, 100, 900, 700},
+		monitor:    monitor,
+	}
+	with := func(change func(f *windowFacts)) windowFacts {
+		f := normal
+		change(&f)
+		return f
+	}
+	tests := []struct {
+		name string
+		f    windowFacts
+		want windowState
+	}{
+		{
+			name: "normal",
+			f:    normal,
+			want: normalWindow,
+		},
+		{
+			name: "minimized",
+			f:    with(func(f *windowFacts) { f.minimized = true }),
+			want: minimizedWindow,
+		},
+		{
+			name: "minimized after being maximized",
+			f:    with(func(f *windowFacts) { f.minimized, f.maximized = true, true }),
+			want: minimizedWindow,
+		},
+		{
+			name: "maximized",
+			f: with(func(f *windowFacts) {
+				f.maximized = true
+				f.windowRect = rect{-8, -8, 1928, 1048}
+			}),
+			want: maximizedWindow,
+		},
+		{
+			name: "fullscreen without a caption",
+			f: with(func(f *windowFacts) {
+				f.hasCaption = false
+				f.windowRect = monitor
+				f.normalRect = monitor
+			}),
+			want: fullscreenWindow,
+		},
+		{
+			name: "fullscreen larger than the monitor",
+			f: with(func(f *windowFacts) {
+				f.hasCaption = false
+				f.windowRect = rect{-1, -1, 1921, 1081}
+			}),
+			want: fullscreenWindow,
+		},
+		{
+			name: "covering the monitor with a caption is not fullscreen",
+			f: with(func(f *windowFacts) {
+				f.windowRect = monitor
+				f.normalRect = monitor
+			}),
+			want: normalWindow,
+		},
+		{
+			name: "without a caption but smaller than the monitor",
+			f:    with(func(f *windowFacts) { f.hasCaption = false }),
+			want: normalWindow,
+		},
+		{
+			name: "snapped to the left half",
+			f:    with(func(f *windowFacts) { f.windowRect = rect{0, 0, 960, 1040} }),
+			want: snappedWindow,
+		},
+		{
+			name: "snapped without a caption",
+			f: with(func(f *windowFacts) {
+				f.hasCaption = false
+				f.windowRect = rect{960, 0, 1920, 1040}
+			}),
+			want: snappedWindow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyWindowState(tt.f); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspaceRect(t *testing.T) {
+	window := rect{200, 150, 1000, 750}
+	tests := []struct {
+		name       string
+		work       rect
+		monitor    rect
+		toolWindow bool
+		want       rect
+	}{
+		{
+			name:    "taskbar at the bottom",
+			work:    rect{0, 0, 1920, 1040},
+			monitor: rect{0, 0, 1920, 1080},
+			want:    window,
+		},
+		{
+			name:    "taskbar on the left",
+			work:    rect{48, 0, 1920, 1080},
+			monitor: rect{0, 0, 1920, 1080},
+			want:    rect{152, 150, 952, 750},
+		},
+		{
+			name:    "taskbar at the top",
+			work:    rect{0, 40, 1920, 1080},
+			monitor: rect{0, 0, 1920, 1080},
+			want:    rect{200, 110, 1000, 710},
+		},
+		{
+			name:    "secondary monitor with the taskbar on the left",
+			work:    rect{-1872, 0, 0, 1080},
+			monitor: rect{-1920, 0, 0, 1080},
+			want:    rect{152, 150, 952, 750},
+		},
+		{
+			name:       "tool window",
+			work:       rect{48, 40, 1920, 1080},
+			monitor:    rect{0, 0, 1920, 1080},
+			toolWindow: true,
+			want:       window,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := workspaceRect(window, tt.work, tt.monitor, tt.toolWindow)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			back := screenRect(got, tt.work, tt.monitor, tt.toolWindow)
+			if back != window {
+				t.Errorf("converted back to %v, want %v", back, window)
+			}
+		})
+	}
+}
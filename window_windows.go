@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/gonutz/w32"
+)
+
+func queryWindowState(window w32.HWND, info w32.MONITORINFO) windowState {
+	var p w32.WINDOWPLACEMENT
+	if !w32.GetWindowPlacement(window, &p) {
+		return normalWindow
+	}
+	r := w32.GetWindowRect(window)
+	if r == nil {
+		return normalWindow
+	}
+	normal := screenRect(
+		fromRECT(p.RcNormalPosition),
+		fromRECT(info.RcWork),
+		fromRECT(info.RcMonitor),
+		isToolWindow(window),
+	)
+	style := uint32(w32.GetWindowLong(window, w32.GWL_STYLE))
+	return classifyWindowState(windowFacts{
+		minimized:  p.ShowCmd == w32.SW_SHOWMINIMIZED,
+		maximized:  p.ShowCmd == w32.SW_SHOWMAXIMIZED,
+		hasCaption: style&w32.WS_CAPTION == w32.WS_CAPTION,
+		windowRect: fromRECT(*r),
+		normalRect: normal,
+		monitor:    fromRECT(info.RcMonitor),
+	})
+}
+
+func isToolWindow(window w32.HWND) bool {
+	exStyle := uint32(w32.GetWindowLong(window, w32.GWL_EXSTYLE))
+	return exStyle&w32.WS_EX_TOOLWINDOW != 0
+}
+
+// placeWindow moves the window to the given rect in screen coordinates.
+//
+// Maximized, minimized and snapped windows remember a restore rect that
+// SetWindowPos does not touch, so un-maximizing the window later would jump
+// back to where it was before. This is why the restore rect is set through
+// the window placement first and the window is shown normally. SetWindowPos
+// then applies the rect to what the window actually looks like.
+func placeWindow(window w32.HWND, r rect, info w32.MONITORINFO) {
+	state := queryWindowState(window, info)
+	if state != normalWindow {
+		var p w32.WINDOWPLACEMENT
+		if w32.GetWindowPlacement(window, &p) {
+			p.Flags = 0
+			p.ShowCmd = w32.SW_SHOWNORMAL
+			p.RcNormalPosition = toRECT(workspaceRect(
+				r,
+				fromRECT(info.RcWork),
+				fromRECT(info.RcMonitor),
+				isToolWindow(window),
+			))
+			w32.SetWindowPlacement(window, &p)
+		}
+	}
+	w32.SetWindowPos(
+		window, 0,
+		r.left, r.top, r.width(), r.height(),
+		w32.SWP_ASYNCWINDOWPOS|w32.SWP_NOACTIVATE|w32.SWP_NOOWNERZORDER|w32.SWP_NOZORDER|w32.SWP_SHOWWINDOW,
+	)
+}
+
+// tileableWindows returns the windows that can be tiled together, from top
+// to bottom in z-order.
+func tileableWindows(info w32.MONITORINFO, cfg config, except w32.HWND) []w32.HWND {
+	var tileable []w32.HWND
+	for _, window := range enumWindows() {
+		if window != except && isTileable(window, info, cfg) {
+			tileable = append(tileable, window)
+		}
+	}
+	return tileable
+}
+
+// isTileable reports whether the window is a visible, unowned window with a
+// title that is not ignored, on the current virtual desktop.
+func isTileable(window w32.HWND, info w32.MONITORINFO, cfg config) bool {
+	return isUsableFor(window, info, cfg, forTiling)
+}
+
+// isTileableOnAnyDesktop is like isTileable but includes windows on other
+// virtual desktops.
+func isTileableOnAnyDesktop(window w32.HWND, info w32.MONITORINFO, cfg config) bool {
+	return isUsableFor(window, info, cfg, forWorkspaces)
+}
+
+func isUsableFor(window w32.HWND, info w32.MONITORINFO, cfg config, p windowPurpose) bool {
+	a := queryWindowAttributes(window)
+	if _, ok := classifyWindow(a, p); !ok {
+		return false
+	}
+	return !ignoreWindow(a.class, queryWindowState(window, info), cfg)
+}
+
+// queryWindowAttributes gathers what classifyWindow needs to know about the
+// window.
+func queryWindowAttributes(window w32.HWND) windowAttributes {
+	class, _ := w32.GetClassName(window)
+	_, pid := w32.GetWindowThreadProcessId(window)
+	return windowAttributes{
+		class:        class,
+		visible:      w32.IsWindowVisible(window),
+		cloaked:      isCloaked(window),
+		otherDesktop: !desktops().onCurrent(uintptr(window)),
+		toolWindow:   isToolWindow(window),
+		owned:        w32.GetWindow(window, w32.GW_OWNER) != 0,
+		hasTitle:     w32.GetWindowTextLength(window) > 0,
+		own:          uint32(pid) == uint32(w32.GetCurrentProcessId()),
+	}
+}
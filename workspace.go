@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspace is a snapshot of where the windows on a virtual desktop are. It
+// is saved with save-workspace and restored with restore-workspace, each
+// desktop has its own workspaces.
+type workspace struct {
+	Name string `json:"name"`
+	// Desktop is the id of the virtual desktop, "" without virtual desktops.
+	Desktop string        `json:"desktop"`
+	Windows []savedWindow `json:"windows"`
+}
+
+// savedWindow is a window in a workspace. Windows get new handles when they
+// are opened again, they are found by their program, class and title
+// instead.
+type savedWindow struct {
+	App   string `json:"app"`
+	Class string `json:"class"`
+	Title string `json:"title"`
+	// Monitor is the id of the monitor, see monitorIdentity.
+	Monitor string `json:"monitor"`
+	// X, Y, Width and Height are in pixels relative to the monitor's work
+	// area.
+	X         int  `json:"x"`
+	Y         int  `json:"y"`
+	Width     int  `json:"width"`
+	Height    int  `json:"height"`
+	Maximized bool `json:"maximized,omitempty"`
+}
+
+func (w savedWindow) rect() rect {
+	return rect{left: w.X, top: w.Y, right: w.X + w.Width, bottom: w.Y + w.Height}
+}
+
+func workspacesPath() string {
+	return filepath.Join(configDir(), "workspaces.json")
+}
+
+// loadWorkspaces reads all saved workspaces. No file means no workspaces.
+func loadWorkspaces() ([]workspace, error) {
+	data, err := ioutil.ReadFile(workspacesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []workspace
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("invalid workspaces file %s: %w", workspacesPath(), err)
+	}
+	return all, nil
+}
+
+// storeWorkspace saves the workspace, replacing the one of the same name on
+// the same desktop.
+func storeWorkspace(w workspace) error {
+	all, err := loadWorkspaces()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range all {
+		if all[i].Desktop == w.Desktop && strings.EqualFold(all[i].Name, w.Name) {
+			all[i] = w
+			replaced = true
+		}
+	}
+	if !replaced {
+		all = append(all, w)
+	}
+	data, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir(), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(workspacesPath(), data, 0666)
+}
+
+func findWorkspace(all []workspace, name, desktop string) (workspace, bool) {
+	for _, w := range all {
+		if w.Desktop == desktop && strings.EqualFold(w.Name, name) {
+			return w, true
+		}
+	}
+	return workspace{}, false
+}
+
+// openWindow is what identifies a window that is open right now.
+type openWindow struct {
+	app, class, title string
+}
+
+// matchWindows finds the open windows for the saved ones. The result has the
+// index into open for each saved window, or -1 if it is not open. Windows
+// with the same program, class and title are matched first, then windows of
+// the same program and class, since titles often contain the name of the open
+// document. Every open window is used only once.
+func matchWindows(saved []savedWindow, open []openWindow) []int {
+	matches := make([]int, len(saved))
+	used := make([]bool, len(open))
+	for i := range matches {
+		matches[i] = -1
+	}
+	for _, sameTitle := range []bool{true, false} {
+		for i, s := range saved {
+			if matches[i] != -1 {
+				continue
+			}
+			for j, o := range open {
+				if used[j] ||
+					!strings.EqualFold(s.App, o.app) ||
+					s.Class != o.class ||
+					sameTitle && s.Title != o.title {
+					continue
+				}
+				matches[i] = j
+				used[j] = true
+				break
+			}
+		}
+	}
+	return matches
+}
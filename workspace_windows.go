@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/gonutz/w32"
+)
+
+func saveWorkspaceCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("save-workspace", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	nameFlag := flags.String("name", defaultWorkspace, "name of the workspace")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	w, err := saveCurrentWorkspace(cfg, *nameFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(
+		stdout, "saved %d windows as workspace %q of %s\n",
+		len(w.Windows), w.Name, desktops().name(w.Desktop),
+	)
+	return nil
+}
+
+func restoreWorkspaceCommand(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("restore-workspace", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	nameFlag := flags.String("name", defaultWorkspace, "name of the workspace")
+	desktopFlag := flags.String(
+		"desktop", "",
+		"virtual desktop that the workspace was saved on, by number or name,\n"+
+			"the current one by default",
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	desktop := desktops().current()
+	if *desktopFlag != "" {
+		desktop, err = findDesktop(desktops(), *desktopFlag)
+		if err != nil {
+			return err
+		}
+	}
+	restored, missing, err := restoreWorkspace(cfg, *nameFlag, desktop)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "restored %d windows\n", restored)
+	for _, title := range missing {
+		fmt.Fprintf(stdout, "not open: %s\n", title)
+	}
+	return nil
+}
+
+// saveCurrentWorkspace saves where the tileable windows on the current
+// virtual desktop are.
+func saveCurrentWorkspace(cfg config, name string) (workspace, error) {
+	defer usePhysicalPixels()()
+
+	vd := desktops()
+	w := workspace{Name: name, Desktop: vd.current()}
+	for _, window := range enumWindows() {
+		monitor := w32.MonitorFromWindow(window, w32.MONITOR_DEFAULTTONULL)
+		var info w32.MONITORINFO
+		if monitor == 0 || !w32.GetMonitorInfo(monitor, &info) ||
+			!isTileable(window, info, cfg) {
+			continue
+		}
+		state := queryWindowState(window, info)
+		if state == minimizedWindow {
+			continue
+		}
+		r := fromRECT(info.RcWork)
+		if state != maximizedWindow {
+			if windowRect := w32.GetWindowRect(window); windowRect != nil {
+				r = fromRECT(*windowRect)
+			}
+		}
+		r = r.offset(-int(info.RcWork.Left), -int(info.RcWork.Top))
+		class, _ := w32.GetClassName(window)
+		w.Windows = append(w.Windows, savedWindow{
+			App:       windowApp(window),
+			Class:     class,
+			Title:     w32.GetWindowText(window),
+			Monitor:   identifyMonitor(monitor).id(),
+			X:         r.left,
+			Y:         r.top,
+			Width:     r.width(),
+			Height:    r.height(),
+			Maximized: state == maximizedWindow,
+		})
+	}
+	return w, storeWorkspace(w)
+}
+
+// restoreWorkspace puts the open windows back to where they were when the
+// workspace was saved on the desktop. Only windows on that desktop are used.
+// Windows does not let programs move the windows of others between desktops.
+// It returns how many windows were restored and the titles of the saved
+// windows that are not open.
+func restoreWorkspace(cfg config, name, desktop string) (int, []string, error) {
+	defer usePhysicalPixels()()
+
+	all, err := loadWorkspaces()
+	if err != nil {
+		return 0, nil, err
+	}
+	vd := desktops()
+	w, ok := findWorkspace(all, name, desktop)
+	if !ok {
+		return 0, nil, fmt.Errorf("there is no workspace %q on %s", name, vd.name(desktop))
+	}
+
+	var windows []w32.HWND
+	var open []openWindow
+	for _, window := range enumWindows() {
+		monitor := w32.MonitorFromWindow(window, w32.MONITOR_DEFAULTTONEAREST)
+		var info w32.MONITORINFO
+		if !w32.GetMonitorInfo(monitor, &info) ||
+			!isTileableOnAnyDesktop(window, info, cfg) {
+			continue
+		}
+		if on := vd.windowDesktop(uintptr(window)); on != "" && on != desktop {
+			continue
+		}
+		class, _ := w32.GetClassName(window)
+		windows = append(windows, window)
+		open = append(open, openWindow{
+			app:   windowApp(window),
+			class: class,
+			title: w32.GetWindowText(window),
+		})
+	}
+
+	handles := enumMonitors()
+	var identities []monitorIdentity
+	for _, m := range handles {
+		identities = append(identities, identifyMonitor(m))
+	}
+
+	restored := 0
+	var missing []string
+	for i, j := range matchWindows(w.Windows, open) {
+		saved := w.Windows[i]
+		if j == -1 {
+			missing = append(missing, saved.Title)
+			continue
+		}
+		window := windows[j]
+		monitor := w32.MonitorFromWindow(window, w32.MONITOR_DEFAULTTONEAREST)
+		if m, ok := matchMonitor(saved.Monitor, identities); ok {
+			monitor = handles[m]
+		}
+		var info w32.MONITORINFO
+		if !w32.GetMonitorInfo(monitor, &info) {
+			continue
+		}
+		r := saved.rect().offset(int(info.RcWork.Left), int(info.RcWork.Top))
+		log.infof("restoring window %q at %v", saved.Title, r)
+		placeWindow(window, r, info)
+		if saved.Maximized {
+			w32.ShowWindowAsync(window, w32.SW_MAXIMIZE)
+		}
+		restored++
+	}
+	return restored, missing, nil
+}